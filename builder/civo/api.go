@@ -0,0 +1,47 @@
+package civo
+
+import (
+	"github.com/civo/civogo"
+)
+
+// CivoAPI is the subset of the Civo API used by the builder. It is
+// satisfied by *civogo.Client extended with the reserved IP endpoints,
+// and by civofake.Client for offline use.
+type CivoAPI interface {
+	ListRegions() ([]civogo.Region, error)
+	ListSizes(region string) ([]civogo.InstanceSize, error)
+	ListTemplates() ([]civogo.Template, error)
+	FindTemplate(search string) (*civogo.Template, error)
+	GetDefaultNetwork() (*civogo.Network, error)
+	ListNetworks() ([]civogo.Network, error)
+
+	ListFirewalls() ([]civogo.Firewall, error)
+	NewFirewall(name string) (*civogo.FirewallResult, error)
+	DeleteFirewall(id string) (*civogo.SimpleResponse, error)
+	NewFirewallRule(r *civogo.FirewallRuleConfig) (*civogo.FirewallRule, error)
+	ListFirewallRules(id string) ([]civogo.FirewallRule, error)
+	DeleteFirewallRule(id string, ruleID string) (*civogo.SimpleResponse, error)
+
+	CreateInstance(config *civogo.InstanceConfig) (*civogo.Instance, error)
+	GetInstance(id string) (*civogo.Instance, error)
+	ListAllInstances() ([]civogo.Instance, error)
+	StopInstance(id string) (*civogo.SimpleResponse, error)
+	DeleteInstance(id string) (*civogo.SimpleResponse, error)
+	SetInstanceFirewall(id, firewallID string) (*civogo.SimpleResponse, error)
+
+	ListReservedIPs(region string) ([]ReservedIP, error)
+	NewReservedIP(name, region string) (*ReservedIP, error)
+	GetReservedIP(id, region string) (*ReservedIP, error)
+	DeleteReservedIP(id, region string) error
+	AssignReservedIP(id, instanceID, region string) error
+	UnassignReservedIP(id, region string) error
+
+	ListSSHKeys() ([]civogo.SSHKey, error)
+	NewSSHKey(name string, publicKey string) (*civogo.SimpleResponse, error)
+	DeleteSSHKey(id string) (*civogo.SimpleResponse, error)
+
+	CreateSnapshot(name string, r *civogo.SnapshotConfig) (*civogo.Snapshot, error)
+	ListSnapshots() ([]civogo.Snapshot, error)
+	FindSnapshot(search string) (*civogo.Snapshot, error)
+	DeleteSnapshot(name string) (*civogo.SimpleResponse, error)
+}
@@ -4,8 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
-
-	"github.com/civo/civogo"
+	"time"
 )
 
 // Artifact ...
@@ -16,8 +15,28 @@ type Artifact struct {
 	SnapshotID string
 	// The name of the region
 	RegionNames []string
+	// The ID of the snapshot in each region
+	RegionSnapshotIDs map[string]string
+	// The ID of the template or snapshot the build was launched from
+	SourceImageID string
+	// The name of the template or snapshot the build was launched from
+	SourceImageName string
+	// The ID of the template the build was launched from, if any
+	SourceTemplateID string
+	// The size of the build instance
+	Size string
+	// The ID of the build instance
+	InstanceID string
+	// How long the build took
+	BuildDuration time.Duration
+	// The size of the snapshot
+	SnapshotSizeBytes int64
+	// The tags applied to the build instance
+	Tags []string
+	// Data made available to post-processors, under "generated_data"
+	StateData map[string]interface{}
 	// The client for making API calls
-	Client *civogo.Client
+	Client CivoAPI
 }
 
 // BuilderId ...
@@ -33,21 +52,56 @@ func (*Artifact) Files() []string {
 
 // Id ...
 func (a *Artifact) Id() string {
+	if a.SnapshotID == "" {
+		return ""
+	}
 	return fmt.Sprintf("%s:%s", strings.Join(a.RegionNames[:], ","), a.SnapshotID)
 }
 
 // String ...
 func (a *Artifact) String() string {
+	if a.SnapshotID == "" {
+		return "No snapshot was created (skip_create_image)"
+	}
 	return fmt.Sprintf("A snapshot was created: '%s' (ID: %s) in regions '%s'", a.SnapshotName, a.SnapshotID, strings.Join(a.RegionNames[:], ","))
 }
 
 // State ...
 func (a *Artifact) State(name string) interface{} {
-	return nil
+	switch name {
+	case "snapshot_id":
+		return a.SnapshotID
+	case "snapshot_name":
+		return a.SnapshotName
+	case "region_snapshot_ids":
+		return a.RegionSnapshotIDs
+	case "source_template_id":
+		return a.SourceTemplateID
+	case "size":
+		return a.Size
+	case "instance_id":
+		return a.InstanceID
+	case "build_duration":
+		// State goes over gob RPC to other plugins, which can not encode
+		// a time.Duration
+		return int64(a.BuildDuration / time.Second)
+	case "snapshot_size_bytes":
+		return a.SnapshotSizeBytes
+	case "source_image_id":
+		return a.SourceImageID
+	case "source_image_name":
+		return a.SourceImageName
+	case "tags":
+		return a.Tags
+	}
+	return a.StateData[name]
 }
 
 // Destroy ...
 func (a *Artifact) Destroy() error {
+	if a.SnapshotID == "" {
+		return nil
+	}
 	log.Printf("Destroying image: %s (%s)", a.SnapshotID, a.SnapshotName)
 	_, err := a.Client.DeleteSnapshot(a.SnapshotID)
 	return err
@@ -0,0 +1,46 @@
+package civo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	// Registers the types Packer sends over RPC
+	_ "github.com/hashicorp/packer-plugin-sdk/rpc"
+)
+
+func TestArtifactStateGobEncodes(t *testing.T) {
+	a := &Artifact{
+		SnapshotName:      "packer-test",
+		SnapshotID:        "snapshot-1",
+		RegionNames:       []string{"LON1"},
+		RegionSnapshotIDs: map[string]string{"LON1": "snapshot-1"},
+		SourceImageID:     "template-1",
+		SourceImageName:   "debian-buster",
+		SourceTemplateID:  "template-1",
+		Size:              "g3.small",
+		InstanceID:        "instance-1",
+		BuildDuration:     90*time.Second + 500*time.Millisecond,
+		SnapshotSizeBytes: 5 << 30,
+		Tags:              []string{"packer-civo"},
+		StateData:         map[string]interface{}{"generated_data": map[string]interface{}{"SnapshotID": "snapshot-1"}},
+	}
+
+	names := []string{
+		"snapshot_id", "snapshot_name", "region_snapshot_ids", "source_image_id", "source_image_name",
+		"source_template_id", "size", "instance_id", "build_duration", "snapshot_size_bytes", "tags",
+		"generated_data",
+	}
+	for _, name := range names {
+		// As the RPC server of the SDK sends it
+		reply := a.State(name)
+		if err := gob.NewEncoder(&bytes.Buffer{}).Encode(&reply); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+
+	if got := a.State("build_duration"); got != int64(90) {
+		t.Fatalf("build_duration is %#v, want 90 seconds", got)
+	}
+}
@@ -5,15 +5,17 @@ package civo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/civo/civogo"
 	"github.com/hashicorp/hcl/v2/hcldec"
-	"github.com/hashicorp/packer/common"
-	"github.com/hashicorp/packer/helper/communicator"
-	"github.com/hashicorp/packer/helper/multistep"
-	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // BuilderID unique id for the builder
@@ -23,6 +25,10 @@ const BuilderID = "pearkes.civo"
 type Builder struct {
 	config Config
 	runner multistep.Runner
+
+	// Client, when set, is used instead of a client created from
+	// api_token. This allows running the builder against a fake API.
+	Client CivoAPI
 }
 
 // ConfigSpec ...
@@ -35,14 +41,32 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		return nil, warnings, errs
 	}
 
-	return nil, nil, nil
+	// Available to provisioners and post-processors with the build function
+	generatedData := []string{
+		"SourceImageName",
+		"SourceImageID",
+		"TemplateID",
+		"Region",
+		"InstanceID",
+		"PublicIP",
+		"PrivateIP",
+		"SnapshotID",
+	}
+
+	return generatedData, warnings, nil
 }
 
 // Run ...
-func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
-	client, err := civogo.NewClient(b.config.APIToken)
-	if err != nil {
-		return nil, fmt.Errorf("civo: %s", err)
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	start := time.Now()
+
+	client := b.Client
+	if client == nil {
+		c, err := NewClient(b.config.APIToken)
+		if err != nil {
+			return nil, fmt.Errorf("civo: %s", err)
+		}
+		client = c
 	}
 
 	// Set up the state
@@ -52,50 +76,120 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
+	j, err := openJournal(b.config.PackerBuildName)
+	switch {
+	case errors.Is(err, errJournalLocked):
+		// Its resources are still in use, and it records them itself
+		ui.Say("Another run of this build is in progress, the resources of this run will not be tracked in case it is killed")
+	case err != nil:
+		ui.Error(fmt.Sprintf("Error opening resource journal, resources left by killed builds will not be tracked: %s", err))
+	default:
+		defer j.close()
+		state.Put("journal", j)
+	}
+
 	// Build the steps
 	steps := []multistep.Step{
+		new(stepCheckJournal),
+		&stepCheckSnapshotName{
+			Force: b.config.ForceDeleteSnapshot || b.config.PackerForce,
+		},
 		&stepCreateSSHKey{
 			Debug:        b.config.PackerDebug,
 			DebugKeyPath: fmt.Sprintf("civo_%s.pem", b.config.PackerBuildName),
+			KeepDebugKey: b.config.KeepDebugKey,
 		},
+		new(stepCreateFirewall),
 		new(stepCreateInstance),
+		new(stepAttachReservedIP),
 		new(stepInstanceInfo),
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
 			Host:      communicator.CommHost(b.config.Comm.Host(), "instance_ip"),
 			SSHConfig: b.config.Comm.SSHConfigFunc(),
+			WinRMConfig: func(multistep.StateBag) (*communicator.WinRMConfig, error) {
+				// The password may only be known once the instance is up
+				return &communicator.WinRMConfig{
+					Username: b.config.Comm.WinRMUser,
+					Password: b.config.Comm.WinRMPassword,
+				}, nil
+			},
 		},
-		new(common.StepProvision),
-		&common.StepCleanupTempKeys{
+		new(commonsteps.StepProvision),
+		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.Comm,
 		},
-		new(stepShutdown),
-		new(stepPowerOff),
-		&stepSnapshot{
-			snapshotTimeout: b.config.SnapshotTimeout,
-		},
+	}
+	if !b.config.SkipCreateImage {
+		steps = append(steps,
+			new(stepGeneralize),
+			new(stepShutdown),
+			new(stepPowerOff),
+			&stepSnapshot{
+				snapshotTimeout: b.config.SnapshotTimeout,
+			},
+		)
 	}
 
 	// Run the steps
-	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner = commonsteps.NewRunner(steps, b.config.PackerConfig, &onErrorUi{
+		Ui:     ui,
+		config: &b.config,
+		state:  state,
+	})
+	if r, ok := b.runner.(*multistep.DebugRunner); ok {
+		r.PauseFn = sshDebugPauseFn(ui, &b.config, r.PauseFn)
+	}
 	b.runner.Run(ctx, state)
 
 	// If there was an error, return that
 	if rawErr, ok := state.GetOk("error"); ok {
+		if _, aborted := state.GetOk("aborted"); aborted || b.config.PackerOnError == "abort" {
+			if cmd := sshDebugCommand(&b.config, state); cmd != "" {
+				ui.Message(fmt.Sprintf("The instance was left running, to debug it: %s", cmd))
+			}
+		}
 		return nil, rawErr.(error)
 	}
 
+	// If the build was cancelled or halted, there is no artifact
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	source := state.Get("source_image").(*sourceImage)
+	artifact := &Artifact{
+		SourceImageID:    source.ID(),
+		SourceImageName:  source.Name,
+		SourceTemplateID: source.TemplateID,
+		Size:             b.config.Size,
+		InstanceID:       state.Get("instance_id").(string),
+		Tags:             state.Get("instance_tags").([]string),
+		StateData:        map[string]interface{}{"generated_data": state.Get("generated_data")},
+		Client:           client,
+	}
+
+	if b.config.SkipCreateImage {
+		ui.Say("skip_create_image is set, no snapshot was created")
+		artifact.BuildDuration = time.Since(start)
+		return artifact, nil
+	}
+
 	if _, ok := state.GetOk("snapshot_name"); !ok {
 		log.Println("Failed to find snapshot_name in state. Bug?")
 		return nil, nil
 	}
 
-	artifact := &Artifact{
-		SnapshotName: state.Get("snapshot_name").(string),
-		SnapshotID:   state.Get("snapshot_id").(string),
-		RegionNames:  state.Get("regions").([]string),
-		Client:       client,
-	}
+	snapshot := state.Get("snapshot").(*civogo.Snapshot)
+	artifact.SnapshotName = state.Get("snapshot_name").(string)
+	artifact.SnapshotID = state.Get("snapshot_id").(string)
+	artifact.RegionNames = state.Get("regions").([]string)
+	artifact.RegionSnapshotIDs = map[string]string{b.config.Region: snapshot.ID}
+	artifact.SnapshotSizeBytes = int64(snapshot.SizeGigabytes) << 30
+	artifact.BuildDuration = time.Since(start)
 
 	return artifact, nil
 }
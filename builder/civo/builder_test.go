@@ -0,0 +1,562 @@
+package civo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+	"github.com/gofrs/flock"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// testConfig returns a minimal valid configuration, with overrides
+// applied on top of it.
+func testConfig(overrides map[string]interface{}) map[string]interface{} {
+	raw := map[string]interface{}{
+		"packer_build_name": "test",
+		"api_token":         "token",
+		"region":            "LON1",
+		"size":              "g3.small",
+		"template":          "debian-buster",
+		"snapshot_name":     "packer-test",
+		"communicator":      "none",
+	}
+	for k, v := range overrides {
+		raw[k] = v
+	}
+	return raw
+}
+
+// syncBuffer is a bytes.Buffer safe to write to from the goroutines
+// the SDK runs steps in.
+type syncBuffer struct {
+	mu  chan struct{}
+	buf bytes.Buffer
+}
+
+func newSyncBuffer() *syncBuffer {
+	return &syncBuffer{mu: make(chan struct{}, 1)}
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu <- struct{}{}
+	defer func() { <-b.mu }()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu <- struct{}{}
+	defer func() { <-b.mu }()
+	return b.buf.String()
+}
+
+func testUi(out *syncBuffer, input string) packersdk.Ui {
+	return &packersdk.BasicUi{
+		Reader:      strings.NewReader(input),
+		Writer:      out,
+		ErrorWriter: out,
+		PB:          &packersdk.NoopProgressTracker{},
+	}
+}
+
+// builderEnv runs builds against a fake API, without ever sleeping.
+type builderEnv struct {
+	t        *testing.T
+	client   *civofake.Client
+	out      *syncBuffer
+	hook     *packersdk.MockHook
+	cacheDir string
+}
+
+func newBuilderEnv(t *testing.T) *builderEnv {
+	t.Helper()
+	cacheDir := t.TempDir()
+	t.Setenv("PACKER_CACHE_DIR", cacheDir)
+	civo.UseFakeClock(t)
+
+	return &builderEnv{
+		t:        t,
+		client:   civofake.NewClient(),
+		out:      newSyncBuffer(),
+		hook:     &packersdk.MockHook{},
+		cacheDir: cacheDir,
+	}
+}
+
+func (e *builderEnv) run(ctx context.Context, overrides map[string]interface{}) (packersdk.Artifact, error) {
+	e.t.Helper()
+	b := &civo.Builder{Client: e.client}
+	if _, _, err := b.Prepare(testConfig(overrides)); err != nil {
+		e.t.Fatalf("Prepare: %s", err)
+	}
+	return b.Run(ctx, testUi(e.out, ""), e.hook)
+}
+
+// assertCleanedUp checks that the build left nothing behind in the
+// account nor in the journal, besides the given reserved IPs.
+func (e *builderEnv) assertCleanedUp(keepIPs ...string) {
+	e.t.Helper()
+
+	if instances := e.client.Instances(); len(instances) != 0 {
+		e.t.Errorf("instances left: %+v", instances)
+	}
+	if keys := e.client.SSHKeys(); len(keys) != 0 {
+		e.t.Errorf("ssh keys left: %+v", keys)
+	}
+	firewalls, _ := e.client.ListFirewalls()
+	if len(firewalls) != 0 {
+		e.t.Errorf("firewalls left: %+v", firewalls)
+	}
+	var ips []string
+	for _, ip := range e.client.ReservedIPs() {
+		if ip.AssignedTo.ID != "" {
+			e.t.Errorf("reserved IP %s still assigned to %s", ip.ID, ip.AssignedTo.ID)
+		}
+		ips = append(ips, ip.ID)
+	}
+	if len(ips) != len(keepIPs) || (len(ips) > 0 && !reflect.DeepEqual(ips, keepIPs)) {
+		e.t.Errorf("reserved IPs left: %v, want %v", ips, keepIPs)
+	}
+
+	journals, _ := filepath.Glob(filepath.Join(e.cacheDir, "civo", "journal", "*.json"))
+	if len(journals) != 0 {
+		e.t.Errorf("journal not emptied: %v", journals)
+	}
+}
+
+// assertCallOrder checks that methods were called in this order, with
+// any other calls in between.
+func assertCallOrder(t *testing.T, client *civofake.Client, methods ...string) {
+	t.Helper()
+	calls := client.Calls()
+	next := 0
+	for _, call := range calls {
+		if next < len(methods) && call == methods[next] {
+			next++
+		}
+	}
+	if next != len(methods) {
+		t.Fatalf("expected calls %v in order, got %v", methods, calls)
+	}
+}
+
+func called(client *civofake.Client, method string) bool {
+	for _, call := range client.Calls() {
+		if call == method {
+			return true
+		}
+	}
+	return false
+}
+
+// fullConfig creates every resource the builder can create.
+var fullConfig = map[string]interface{}{
+	"temporary_firewall":              true,
+	"temporary_firewall_source_cidrs": []string{"192.0.2.0/24"},
+	"reserved_ip":                     "create",
+}
+
+func TestBuilderRun(t *testing.T) {
+	e := newBuilderEnv(t)
+
+	var statusDuringProvisioning []string
+	e.hook.RunFunc = func(context.Context) error {
+		for _, i := range e.client.Instances() {
+			statusDuringProvisioning = append(statusDuringProvisioning, i.Status)
+		}
+		return nil
+	}
+
+	artifact, err := e.run(context.Background(), fullConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, e.out)
+	}
+	e.assertCleanedUp()
+
+	if !e.hook.RunCalled || e.hook.RunName != packersdk.HookProvision {
+		t.Fatal("provisioners were not run")
+	}
+	if !reflect.DeepEqual(statusDuringProvisioning, []string{civofake.StatusActive}) {
+		t.Fatalf("provisioned instances in states %v", statusDuringProvisioning)
+	}
+
+	snapshots := e.client.Snapshots()
+	if len(snapshots) != 1 {
+		t.Fatalf("expected a single snapshot, got %+v", snapshots)
+	}
+	snapshot := snapshots[0]
+	if snapshot.Name != "packer-test" || snapshot.State != civofake.SnapshotComplete {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	if got := e.client.History(snapshot.ID); !reflect.DeepEqual(got, []string{"pending", "complete"}) {
+		t.Fatalf("snapshot went through %v", got)
+	}
+
+	instanceID := artifact.State("instance_id").(string)
+	want := []string{"BUILDING", "ACTIVE", "STOPPING", "SHUTOFF"}
+	if got := e.client.History(instanceID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("instance went through %v, want %v", got, want)
+	}
+	if snapshot.InstanceID != instanceID {
+		t.Fatalf("snapshot of %s, not of the build instance %s", snapshot.InstanceID, instanceID)
+	}
+
+	assertCallOrder(t, e.client,
+		"ListSnapshots", "NewFirewall", "CreateInstance", "SetInstanceFirewall",
+		"NewReservedIP", "AssignReservedIP", "StopInstance", "CreateSnapshot",
+		"UnassignReservedIP", "DeleteReservedIP", "DeleteInstance", "DeleteFirewall")
+
+	if artifact.Id() != "LON1:"+snapshot.ID {
+		t.Fatalf("artifact ID %q", artifact.Id())
+	}
+	if got := artifact.State("snapshot_id"); got != snapshot.ID {
+		t.Fatalf("snapshot_id state %v", got)
+	}
+	if got := artifact.State("source_image_name"); got != "debian-buster" {
+		t.Fatalf("source_image_name state %v", got)
+	}
+	data, ok := artifact.State("generated_data").(map[string]interface{})
+	if !ok || data["SnapshotID"] != snapshot.ID || data["InstanceID"] != instanceID || data["PublicIP"] == "" {
+		t.Fatalf("generated_data %+v", artifact.State("generated_data"))
+	}
+}
+
+func TestBuilderRunRetriesTransientErrors(t *testing.T) {
+	e := newBuilderEnv(t)
+	// Let stepCheckSnapshotName, which does not retry, through
+	e.client.FailNext("ListSnapshots", nil)
+	for i := 0; i < 3; i++ {
+		e.client.FailNext("GetInstance", civogo.HTTPError{Code: 503, Status: "503 Service Unavailable"})
+		e.client.FailNext("ListSnapshots", civogo.HTTPError{Code: 429, Status: "429 Too Many Requests"})
+	}
+
+	if _, err := e.run(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, e.out)
+	}
+	e.assertCleanedUp()
+}
+
+func TestBuilderRunCleansUpOnFailure(t *testing.T) {
+	injected := errors.New("injected failure")
+	notFound := civogo.HTTPError{Code: 404, Status: "404 Not Found"}
+
+	cases := []struct {
+		name   string
+		method string
+		err    error
+	}{
+		{"creating the firewall", "NewFirewall", injected},
+		{"adding the firewall rule", "NewFirewallRule", injected},
+		{"creating the instance", "CreateInstance", injected},
+		{"applying the firewall", "SetInstanceFirewall", injected},
+		{"allocating the reserved IP", "NewReservedIP", injected},
+		{"waiting for the instance", "GetInstance", notFound},
+		{"attaching the reserved IP", "AssignReservedIP", injected},
+		{"shutting down", "StopInstance", injected},
+		{"creating the snapshot", "CreateSnapshot", injected},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newBuilderEnv(t)
+			e.client.FailNext(tc.method, tc.err)
+
+			_, err := e.run(context.Background(), fullConfig)
+			if err == nil {
+				t.Fatal("expected the build to fail")
+			}
+			e.assertCleanedUp()
+			if snapshots := e.client.Snapshots(); len(snapshots) != 0 {
+				t.Fatalf("snapshots left: %+v", snapshots)
+			}
+		})
+	}
+
+	t.Run("provisioning", func(t *testing.T) {
+		e := newBuilderEnv(t)
+		e.hook.RunFunc = func(context.Context) error {
+			if e.hook.RunName == packersdk.HookProvision {
+				return injected
+			}
+			return nil
+		}
+
+		if _, err := e.run(context.Background(), fullConfig); !errors.Is(err, injected) {
+			t.Fatalf("expected the provisioning error, got %v", err)
+		}
+		e.assertCleanedUp()
+		if called(e.client, "CreateSnapshot") {
+			t.Fatal("snapshot created after provisioning failed")
+		}
+	})
+
+	t.Run("deleting the firewall while the instance is in use", func(t *testing.T) {
+		e := newBuilderEnv(t)
+		for i := 0; i < 3; i++ {
+			e.client.FailNext("DeleteFirewall", civogo.DatabaseFirewallDeleteFailedError)
+		}
+
+		if _, err := e.run(context.Background(), fullConfig); err != nil {
+			t.Fatalf("unexpected error: %s\n%s", err, e.out)
+		}
+		e.assertCleanedUp()
+	})
+}
+
+func TestBuilderRunCancelled(t *testing.T) {
+	e := newBuilderEnv(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e.hook.RunFunc = func(ctx context.Context) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	_, err := e.run(ctx, fullConfig)
+	if err == nil {
+		t.Fatal("expected the build to fail")
+	}
+	e.assertCleanedUp()
+	if called(e.client, "CreateSnapshot") {
+		t.Fatal("snapshot created after cancellation")
+	}
+}
+
+func TestBuilderRunSkipCreateImage(t *testing.T) {
+	e := newBuilderEnv(t)
+
+	artifact, err := e.run(context.Background(), map[string]interface{}{"skip_create_image": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, e.out)
+	}
+	e.assertCleanedUp()
+
+	if artifact.Id() != "" {
+		t.Fatalf("artifact ID %q, want none", artifact.Id())
+	}
+	for _, method := range []string{"ListSnapshots", "StopInstance", "CreateSnapshot"} {
+		if called(e.client, method) {
+			t.Errorf("%s called with skip_create_image", method)
+		}
+	}
+}
+
+func TestBuilderRunExistingSnapshotName(t *testing.T) {
+	t.Run("fails before creating anything", func(t *testing.T) {
+		e := newBuilderEnv(t)
+		e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "LON1", State: "complete"})
+
+		_, err := e.run(context.Background(), nil)
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("expected a name collision error, got %v", err)
+		}
+		if called(e.client, "CreateInstance") {
+			t.Fatal("instance created despite the name collision")
+		}
+	})
+
+	t.Run("same name in another region", func(t *testing.T) {
+		e := newBuilderEnv(t)
+		elsewhere := e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "NYC1", State: "complete"})
+
+		artifact, err := e.run(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s\n%s", err, e.out)
+		}
+		if artifact.State("snapshot_id") == elsewhere.ID {
+			t.Fatal("the snapshot of another region was overwritten")
+		}
+		if snapshots := e.client.Snapshots(); len(snapshots) != 2 {
+			t.Fatalf("snapshots left: %+v", snapshots)
+		}
+	})
+
+	t.Run("replaced with force_delete_snapshot", func(t *testing.T) {
+		e := newBuilderEnv(t)
+		old := e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "LON1", State: "complete"})
+		duplicate := e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "LON1", State: "complete"})
+		other := e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test-2", Region: "LON1", State: "complete"})
+		elsewhere := e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "NYC1", State: "complete"})
+
+		artifact, err := e.run(context.Background(), map[string]interface{}{"force_delete_snapshot": true})
+		if err != nil {
+			t.Fatalf("unexpected error: %s\n%s", err, e.out)
+		}
+		if !strings.Contains(e.out.String(), "overwrites it in place") {
+			t.Fatalf("overwriting the snapshot not reported:\n%s", e.out)
+		}
+
+		// The API overwrites one of the snapshots in place, the build
+		// deletes the others of the region once it is complete
+		newID := artifact.State("snapshot_id").(string)
+		if newID != old.ID && newID != duplicate.ID {
+			t.Fatalf("artifact refers to snapshot %s, not an overwritten one", newID)
+		}
+		if got := e.client.History(newID); !reflect.DeepEqual(got, []string{"complete", "pending", "complete"}) {
+			t.Fatalf("overwritten snapshot went through %v", got)
+		}
+		var ids []string
+		for _, s := range e.client.Snapshots() {
+			ids = append(ids, s.ID)
+		}
+		sort.Strings(ids)
+		want := []string{newID, other.ID, elsewhere.ID}
+		sort.Strings(want)
+		if !reflect.DeepEqual(ids, want) {
+			t.Fatalf("snapshots left: %v, want %v", ids, want)
+		}
+		assertCallOrder(t, e.client, "CreateSnapshot", "ListSnapshots", "DeleteSnapshot")
+	})
+
+	t.Run("replaced with -force", func(t *testing.T) {
+		e := newBuilderEnv(t)
+		e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "LON1", State: "complete"})
+
+		if _, err := e.run(context.Background(), map[string]interface{}{"packer_force": true}); err != nil {
+			t.Fatalf("unexpected error: %s\n%s", err, e.out)
+		}
+		if snapshots := e.client.Snapshots(); len(snapshots) != 1 {
+			t.Fatalf("snapshots left: %+v", snapshots)
+		}
+	})
+}
+
+// stepEnv runs steps on their own, with the state Builder.Run sets up.
+type stepEnv struct {
+	client *civofake.Client
+	config *civo.Config
+	state  *multistep.BasicStateBag
+	out    *syncBuffer
+}
+
+func newStepEnv(t *testing.T, overrides map[string]interface{}) *stepEnv {
+	t.Helper()
+	civo.UseFakeClock(t)
+
+	config := &civo.Config{}
+	if _, err := config.Prepare(testConfig(overrides)); err != nil {
+		t.Fatalf("Prepare: %s", err)
+	}
+
+	e := &stepEnv{
+		client: civofake.NewClient(),
+		config: config,
+		state:  new(multistep.BasicStateBag),
+		out:    newSyncBuffer(),
+	}
+	e.state.Put("config", config)
+	e.state.Put("client", civo.CivoAPI(e.client))
+	e.state.Put("ui", testUi(e.out, ""))
+	return e
+}
+
+// run runs a step, failing the test unless it returns want.
+func (e *stepEnv) run(t *testing.T, step multistep.Step, want multistep.StepAction) {
+	t.Helper()
+	if got := step.Run(context.Background(), e.state); got != want {
+		err, _ := e.state.GetOk("error")
+		t.Fatalf("step returned %v, want %v (error: %v)\n%s", got, want, err, e.out)
+	}
+}
+
+// addInstance adds an instance and makes it the build instance.
+func (e *stepEnv) addInstance(i civogo.Instance) civogo.Instance {
+	if i.Region == "" {
+		i.Region = e.config.Region
+	}
+	i = e.client.AddInstance(i)
+	e.state.Put("instance_id", i.ID)
+	return i
+}
+
+// writeJournal records an instance, and the reserved IPs created for it,
+// in the journal of the test build, as a previous run would have.
+func (e *builderEnv) writeJournal(instance civogo.Instance, ips ...civo.ReservedIP) string {
+	e.t.Helper()
+
+	dir := filepath.Join(e.cacheDir, "civo", "journal")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		e.t.Fatal(err)
+	}
+	path := filepath.Join(dir, "test.json")
+	entry := `{"kind":%q,"id":%q,"name":%q,"region":"LON1","created_at":"2026-01-01T00:00:00Z"}`
+	entries := []string{fmt.Sprintf(entry, "instance", instance.ID, instance.Hostname)}
+	for _, ip := range ips {
+		entries = append(entries, fmt.Sprintf(entry, "reserved ip", ip.ID, ip.Name))
+	}
+	data := `{"resources":[` + strings.Join(entries, ",") + `]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		e.t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuilderRunCleansUpPreviousRun(t *testing.T) {
+	e := newBuilderEnv(t)
+	left := e.client.AddInstance(civogo.Instance{Hostname: "packer-left"})
+	e.writeJournal(left)
+
+	if _, err := e.run(context.Background(), map[string]interface{}{"cleanup_previous_run": true}); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, e.out)
+	}
+	e.assertCleanedUp()
+}
+
+func TestBuilderRunCleansUpPreviousRunReservedIP(t *testing.T) {
+	e := newBuilderEnv(t)
+	left := e.client.AddInstance(civogo.Instance{Hostname: "packer-left"})
+	ip := e.client.AddReservedIP(civo.ReservedIP{
+		Name:       "packer-left",
+		AssignedTo: civo.ReservedIPAssignee{ID: left.ID},
+	})
+	e.writeJournal(left, ip)
+
+	if _, err := e.run(context.Background(), map[string]interface{}{"cleanup_previous_run": true}); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, e.out)
+	}
+	e.assertCleanedUp()
+	if strings.Contains(e.out.String(), "Error deleting") {
+		t.Fatalf("leftover not deleted:\n%s", e.out)
+	}
+	assertCallOrder(t, e.client, "DeleteInstance", "UnassignReservedIP", "DeleteReservedIP", "CreateInstance")
+}
+
+func TestBuilderRunConcurrentRun(t *testing.T) {
+	e := newBuilderEnv(t)
+	live := e.client.AddInstance(civogo.Instance{Hostname: "packer-live"})
+	path := e.writeJournal(live)
+	before, _ := os.ReadFile(path)
+
+	// The other run holds the journal until it is done
+	lock := flock.New(filepath.Join(filepath.Dir(path), "test.lock"))
+	if locked, err := lock.TryLock(); err != nil || !locked {
+		t.Fatalf("could not lock the journal: %v", err)
+	}
+	defer lock.Unlock()
+
+	if _, err := e.run(context.Background(), map[string]interface{}{"cleanup_previous_run": true}); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, e.out)
+	}
+	if !strings.Contains(e.out.String(), "Another run of this build is in progress") {
+		t.Fatalf("concurrent run not reported:\n%s", e.out)
+	}
+
+	instances := e.client.Instances()
+	if len(instances) != 1 || instances[0].ID != live.ID {
+		t.Fatalf("the instance of the other run was deleted, instances left: %+v", instances)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Fatalf("the journal of the other run was overwritten:\n%s", after)
+	}
+}
@@ -0,0 +1,931 @@
+// Package civofake contains an in-memory implementation of the Civo API
+// used by the builder, so that builds can be exercised without an account.
+package civofake
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civogo"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ civo.CivoAPI = (*Client)(nil)
+
+// Instance and snapshot states reported by the fake.
+const (
+	StatusBuilding = "BUILDING"
+	StatusActive   = "ACTIVE"
+	StatusStopping = "STOPPING"
+	StatusShutoff  = "SHUTOFF"
+
+	SnapshotPending  = "pending"
+	SnapshotComplete = "complete"
+)
+
+// Client is a stateful, in-memory stand-in for *civogo.Client.
+//
+// Instances go through BUILDING, ACTIVE and SHUTOFF, and snapshots
+// through pending and complete. Each transition happens after the
+// configured number of reads of the resource, so a waiter polling the
+// fake observes the same sequence of states as against the real API.
+type Client struct {
+	// Number of GetInstance calls an instance stays BUILDING for.
+	BuildPolls int
+	// Number of GetInstance calls a stopping instance takes to be SHUTOFF.
+	StopPolls int
+	// Number of reads, by ListSnapshots or FindSnapshot, a snapshot stays
+	// pending for.
+	SnapshotPolls int
+
+	mu        sync.Mutex
+	seq       int
+	regions   []civogo.Region
+	sizes     []size
+	templates []civogo.Template
+	networks  []civogo.Network
+	firewalls map[string]*firewall
+	ips       map[string]*civo.ReservedIP
+	instances map[string]*instance
+	sshKeys   map[string]civogo.SSHKey
+	snapshots map[string]*snapshot
+	faults    map[string][]error
+	delays    map[string]time.Duration
+	calls     []string
+	history   map[string][]string
+}
+
+type instance struct {
+	civogo.Instance
+	polls int
+}
+
+type size struct {
+	civogo.InstanceSize
+	regions []string
+}
+
+type firewall struct {
+	civogo.Firewall
+	rules []civogo.FirewallRule
+}
+
+type snapshot struct {
+	civogo.Snapshot
+	polls int
+}
+
+// NewClient returns a fake with a single region, LON1, which has a
+// default network and a "debian-buster" template. The region arguments
+// of the API are ignored, except for sizes.
+func NewClient() *Client {
+	c := &Client{
+		BuildPolls:    2,
+		StopPolls:     1,
+		SnapshotPolls: 2,
+		instances:     make(map[string]*instance),
+		sshKeys:       make(map[string]civogo.SSHKey),
+		firewalls:     make(map[string]*firewall),
+		ips:           make(map[string]*civo.ReservedIP),
+		snapshots:     make(map[string]*snapshot),
+		faults:        make(map[string][]error),
+		delays:        make(map[string]time.Duration),
+		history:       make(map[string][]string),
+		regions:       []civogo.Region{{Code: "LON1", Name: "London 1", Default: true}},
+	}
+	c.AddNetwork(civogo.Network{Label: "Default", Default: true})
+	c.AddTemplate(civogo.Template{Code: "debian-buster", Name: "Debian 10 (Buster)"})
+	return c
+}
+
+// AddTemplate registers a template, generating its ID if empty.
+func (c *Client) AddTemplate(t civogo.Template) civogo.Template {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t.ID == "" {
+		t.ID = c.nextID("template")
+	}
+	c.templates = append(c.templates, t)
+	return t
+}
+
+// AddRegion registers a region.
+func (c *Client) AddRegion(r civogo.Region) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.regions = append(c.regions, r)
+}
+
+// AddSize registers an instance size, available in the given regions or
+// in all of them when none is given. It generates the ID if empty.
+func (c *Client) AddSize(s civogo.InstanceSize, regions ...string) civogo.InstanceSize {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s.ID == "" {
+		s.ID = c.nextID("size")
+	}
+	c.sizes = append(c.sizes, size{InstanceSize: s, regions: regions})
+	return s
+}
+
+// AddNetwork registers a network, generating its ID if empty.
+func (c *Client) AddNetwork(n civogo.Network) civogo.Network {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n.ID == "" {
+		n.ID = c.nextID("network")
+	}
+	c.networks = append(c.networks, n)
+	return n
+}
+
+// AddSnapshot registers an existing snapshot, generating its ID if empty.
+func (c *Client) AddSnapshot(s civogo.Snapshot) civogo.Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s.ID == "" {
+		s.ID = c.nextID("snapshot")
+	}
+	c.snapshots[s.ID] = &snapshot{Snapshot: s}
+	c.history[s.ID] = []string{s.State}
+	return s
+}
+
+// AddInstance registers an existing instance, generating its ID if
+// empty. It defaults to ACTIVE.
+func (c *Client) AddInstance(i civogo.Instance) civogo.Instance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i.ID == "" {
+		i.ID = c.nextID("instance")
+	}
+	if i.Status == "" {
+		i.Status = StatusActive
+	}
+	c.instances[i.ID] = &instance{Instance: i}
+	c.history[i.ID] = []string{i.Status}
+	return i
+}
+
+// AddSSHKey registers an existing public key, generating its ID if
+// empty.
+func (c *Client) AddSSHKey(k civogo.SSHKey) civogo.SSHKey {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if k.ID == "" {
+		k.ID = c.nextID("sshkey")
+	}
+	c.sshKeys[k.ID] = k
+	return k
+}
+
+// AddReservedIP registers a reserved IP, generating its ID and address
+// if empty.
+func (c *Client) AddReservedIP(ip civo.ReservedIP) civo.ReservedIP {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	if ip.ID == "" {
+		// Civo IDs are UUIDs, which the builder validates
+		ip.ID = fmt.Sprintf("00000000-0000-4000-8000-%012d", c.seq)
+	}
+	if ip.IP == "" {
+		ip.IP = fmt.Sprintf("10.1.0.%d", c.seq)
+	}
+	c.ips[ip.ID] = &ip
+	return ip
+}
+
+// ReservedIPs returns a copy of the reserved IPs currently known.
+func (c *Client) ReservedIPs() []civo.ReservedIP {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []civo.ReservedIP
+	for _, ip := range c.ips {
+		out = append(out, *ip)
+	}
+	return out
+}
+
+// FailNext makes the next call to method return err instead of
+// touching any state. Calls queue up, one error per call; a nil error
+// lets its call through, to fail a later one.
+func (c *Client) FailNext(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.faults[method] = append(c.faults[method], err)
+}
+
+// SetDelay makes every call to method block for d before running.
+func (c *Client) SetDelay(method string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.delays[method] = d
+}
+
+// History returns the states an instance or snapshot went through, in
+// order, including after it was deleted.
+func (c *Client) History(id string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.history[id]...)
+}
+
+// Calls returns the names of the methods called so far, in order.
+func (c *Client) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.calls...)
+}
+
+// Instances returns the instances that currently exist.
+func (c *Client) Instances() []civogo.Instance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []civogo.Instance
+	for _, i := range c.instances {
+		out = append(out, i.Instance)
+	}
+	return out
+}
+
+// SSHKeys returns the SSH keys that currently exist.
+func (c *Client) SSHKeys() []civogo.SSHKey {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []civogo.SSHKey
+	for _, k := range c.sshKeys {
+		out = append(out, k)
+	}
+	return out
+}
+
+// Snapshots returns the snapshots that currently exist.
+func (c *Client) Snapshots() []civogo.Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []civogo.Snapshot
+	for _, s := range c.snapshots {
+		out = append(out, s.Snapshot)
+	}
+	return out
+}
+
+// ListRegions returns the regions.
+func (c *Client) ListRegions() ([]civogo.Region, error) {
+	if err := c.enter("ListRegions"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	return append([]civogo.Region(nil), c.regions...), nil
+}
+
+// ListSizes returns the sizes available in region, or all of them when
+// region is empty.
+func (c *Client) ListSizes(region string) ([]civogo.InstanceSize, error) {
+	if err := c.enter("ListSizes"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	var out []civogo.InstanceSize
+	for _, s := range c.sizes {
+		if region == "" || len(s.regions) == 0 || slices.Contains(s.regions, region) {
+			out = append(out, s.InstanceSize)
+		}
+	}
+	return out, nil
+}
+
+// ListTemplates returns all templates.
+func (c *Client) ListTemplates() ([]civogo.Template, error) {
+	if err := c.enter("ListTemplates"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	return append([]civogo.Template(nil), c.templates...), nil
+}
+
+// FindTemplate finds a template by part of its ID or code.
+func (c *Client) FindTemplate(search string) (*civogo.Template, error) {
+	if err := c.enter("FindTemplate"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	found := -1
+	for i, t := range c.templates {
+		if t.ID == search || t.Code == search {
+			found = i
+			break
+		}
+		if strings.Contains(t.ID, search) || strings.Contains(t.Code, search) {
+			if found != -1 {
+				return nil, fmt.Errorf("%w: unable to find %s because there were multiple matches", civogo.MultipleMatchesError, search)
+			}
+			found = i
+		}
+	}
+	if found == -1 {
+		return nil, fmt.Errorf("%w: unable to find %s, zero matches", civogo.ZeroMatchesError, search)
+	}
+
+	t := c.templates[found]
+	return &t, nil
+}
+
+// GetDefaultNetwork returns the default network.
+func (c *Client) GetDefaultNetwork() (*civogo.Network, error) {
+	if err := c.enter("GetDefaultNetwork"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	for _, n := range c.networks {
+		if n.Default {
+			return &n, nil
+		}
+	}
+	return nil, fmt.Errorf("No default network found")
+}
+
+// ListNetworks returns all networks.
+func (c *Client) ListNetworks() ([]civogo.Network, error) {
+	if err := c.enter("ListNetworks"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	return append([]civogo.Network(nil), c.networks...), nil
+}
+
+// ListFirewalls returns all firewalls.
+func (c *Client) ListFirewalls() ([]civogo.Firewall, error) {
+	if err := c.enter("ListFirewalls"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	var out []civogo.Firewall
+	for _, f := range c.firewalls {
+		out = append(out, f.Firewall)
+	}
+	return out, nil
+}
+
+// NewFirewall creates a firewall which, like on Civo, comes with a
+// default rule opening every port.
+func (c *Client) NewFirewall(name string) (*civogo.FirewallResult, error) {
+	if err := c.enter("NewFirewall"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	id := c.nextID("firewall")
+	c.firewalls[id] = &firewall{
+		Firewall: civogo.Firewall{ID: id, Name: name},
+		rules: []civogo.FirewallRule{{
+			ID:         c.nextID("rule"),
+			FirewallID: id,
+			Protocol:   "tcp",
+			StartPort:  "1",
+			EndPort:    "65535",
+			Cidr:       []string{"0.0.0.0/0"},
+			Direction:  "ingress",
+		}},
+	}
+	return &civogo.FirewallResult{ID: id, Name: name, Result: civogo.ResultSuccess}, nil
+}
+
+// DeleteFirewall removes a firewall, failing while instances use it.
+func (c *Client) DeleteFirewall(id string) (*civogo.SimpleResponse, error) {
+	if err := c.enter("DeleteFirewall"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	if _, ok := c.firewalls[id]; !ok {
+		return nil, fmt.Errorf("%w: firewall %s not found", civogo.DatabaseFirewallNotFoundError, id)
+	}
+	for _, i := range c.instances {
+		if i.FirewallID == id {
+			return nil, fmt.Errorf("%w: firewall %s is in use by %s", civogo.DatabaseFirewallDeleteFailedError, id, i.ID)
+		}
+	}
+	delete(c.firewalls, id)
+	return &civogo.SimpleResponse{ID: id, Result: civogo.ResultSuccess}, nil
+}
+
+// NewFirewallRule adds a rule to a firewall.
+func (c *Client) NewFirewallRule(r *civogo.FirewallRuleConfig) (*civogo.FirewallRule, error) {
+	if err := c.enter("NewFirewallRule"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	f, ok := c.firewalls[r.FirewallID]
+	if !ok {
+		return nil, fmt.Errorf("%w: firewall %s not found", civogo.DatabaseFirewallNotFoundError, r.FirewallID)
+	}
+	rule := civogo.FirewallRule{
+		ID:         c.nextID("rule"),
+		FirewallID: r.FirewallID,
+		Protocol:   r.Protocol,
+		StartPort:  r.StartPort,
+		EndPort:    r.EndPort,
+		Cidr:       r.Cidr,
+		Direction:  r.Direction,
+		Label:      r.Label,
+	}
+	f.rules = append(f.rules, rule)
+	f.RulesCount = len(f.rules)
+	return &rule, nil
+}
+
+// ListFirewallRules returns the rules of a firewall.
+func (c *Client) ListFirewallRules(id string) ([]civogo.FirewallRule, error) {
+	if err := c.enter("ListFirewallRules"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	f, ok := c.firewalls[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: firewall %s not found", civogo.DatabaseFirewallNotFoundError, id)
+	}
+	return append([]civogo.FirewallRule(nil), f.rules...), nil
+}
+
+// DeleteFirewallRule removes a rule from a firewall.
+func (c *Client) DeleteFirewallRule(id string, ruleID string) (*civogo.SimpleResponse, error) {
+	if err := c.enter("DeleteFirewallRule"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	f, ok := c.firewalls[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: firewall %s not found", civogo.DatabaseFirewallNotFoundError, id)
+	}
+	for i, rule := range f.rules {
+		if rule.ID == ruleID {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			f.RulesCount = len(f.rules)
+			return &civogo.SimpleResponse{ID: ruleID, Result: civogo.ResultSuccess}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: rule %s not found", civogo.DatabaseFirewallRulesFindError, ruleID)
+}
+
+// CreateInstance creates an instance in the BUILDING state.
+func (c *Client) CreateInstance(config *civogo.InstanceConfig) (*civogo.Instance, error) {
+	if err := c.enter("CreateInstance"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	id := c.nextID("instance")
+	i := &instance{Instance: civogo.Instance{
+		ID:          id,
+		Hostname:    config.Hostname,
+		Size:        config.Size,
+		Region:      config.Region,
+		NetworkID:   config.NetworkID,
+		TemplateID:  config.TemplateID,
+		SnapshotID:  config.SnapshotID,
+		InitialUser: config.InitialUser,
+		SSHKey:      config.SSHKeyID,
+		Script:      config.Script,
+		Tags:        config.Tags,
+		Status:      StatusBuilding,
+		PrivateIP:   fmt.Sprintf("192.168.1.%d", c.seq),
+		CreatedAt:   time.Now(),
+	}}
+	if config.PublicIPRequired != "none" && config.PublicIPRequired != "false" {
+		i.PublicIP = fmt.Sprintf("10.0.0.%d", c.seq)
+	}
+	c.instances[id] = i
+	c.history[id] = []string{i.Status}
+
+	out := i.Instance
+	return &out, nil
+}
+
+// ListAllInstances returns all instances, without moving them along
+// their lifecycle.
+func (c *Client) ListAllInstances() ([]civogo.Instance, error) {
+	if err := c.enter("ListAllInstances"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	var out []civogo.Instance
+	for _, i := range c.instances {
+		out = append(out, i.Instance)
+	}
+	return out, nil
+}
+
+// GetInstance returns an instance, moving it along its lifecycle.
+func (c *Client) GetInstance(id string) (*civogo.Instance, error) {
+	if err := c.enter("GetInstance"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	i, ok := c.instances[id]
+	if !ok {
+		// The builder reads instances without civogo's error decoding
+		return nil, civogo.HTTPError{Code: 404, Status: "404 Not Found", Reason: fmt.Sprintf("instance %s not found", id)}
+	}
+
+	i.polls++
+	switch {
+	case i.Status == StatusBuilding && i.polls > c.BuildPolls:
+		c.setStatus(i, StatusActive)
+	case i.Status == StatusStopping && i.polls > c.StopPolls:
+		c.setStatus(i, StatusShutoff)
+	}
+
+	out := i.Instance
+	return &out, nil
+}
+
+// StopInstance starts shutting an instance down.
+func (c *Client) StopInstance(id string) (*civogo.SimpleResponse, error) {
+	if err := c.enter("StopInstance"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	i, ok := c.instances[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: instance %s not found", civogo.DatabaseInstanceNotFoundError, id)
+	}
+	if i.Status != StatusShutoff {
+		c.setStatus(i, StatusStopping)
+	}
+	return &civogo.SimpleResponse{ID: id, Result: civogo.ResultSuccess}, nil
+}
+
+// SetInstanceFirewall applies a firewall to an instance.
+func (c *Client) SetInstanceFirewall(id, firewallID string) (*civogo.SimpleResponse, error) {
+	if err := c.enter("SetInstanceFirewall"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	i, ok := c.instances[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: instance %s not found", civogo.DatabaseInstanceNotFoundError, id)
+	}
+	f, ok := c.firewalls[firewallID]
+	if !ok {
+		return nil, fmt.Errorf("%w: firewall %s not found", civogo.DatabaseFirewallNotFoundError, firewallID)
+	}
+	i.FirewallID = firewallID
+	f.InstancesCount++
+	return &civogo.SimpleResponse{ID: id, Result: civogo.ResultSuccess}, nil
+}
+
+// DeleteInstance removes an instance.
+func (c *Client) DeleteInstance(id string) (*civogo.SimpleResponse, error) {
+	if err := c.enter("DeleteInstance"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	if _, ok := c.instances[id]; !ok {
+		return nil, fmt.Errorf("%w: instance %s not found", civogo.DatabaseInstanceNotFoundError, id)
+	}
+	delete(c.instances, id)
+	return &civogo.SimpleResponse{ID: id, Result: civogo.ResultSuccess}, nil
+}
+
+// ListReservedIPs returns all reserved IPs.
+func (c *Client) ListReservedIPs(region string) ([]civo.ReservedIP, error) {
+	if err := c.enter("ListReservedIPs"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	var out []civo.ReservedIP
+	for _, ip := range c.ips {
+		out = append(out, *ip)
+	}
+	return out, nil
+}
+
+// NewReservedIP allocates a reserved IP.
+func (c *Client) NewReservedIP(name, region string) (*civo.ReservedIP, error) {
+	if err := c.enter("NewReservedIP"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	c.seq++
+	ip := &civo.ReservedIP{
+		ID:   fmt.Sprintf("00000000-0000-4000-8000-%012d", c.seq),
+		Name: name,
+		IP:   fmt.Sprintf("10.1.0.%d", c.seq),
+	}
+	c.ips[ip.ID] = ip
+	out := *ip
+	return &out, nil
+}
+
+// GetReservedIP returns a reserved IP.
+func (c *Client) GetReservedIP(id, region string) (*civo.ReservedIP, error) {
+	if err := c.enter("GetReservedIP"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	ip, ok := c.ips[id]
+	if !ok {
+		return nil, ipNotFound(id)
+	}
+	out := *ip
+	return &out, nil
+}
+
+// DeleteReservedIP releases a reserved IP, failing while it is attached.
+func (c *Client) DeleteReservedIP(id, region string) error {
+	if err := c.enter("DeleteReservedIP"); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+
+	ip, ok := c.ips[id]
+	if !ok {
+		return ipNotFound(id)
+	}
+	if ip.AssignedTo.ID != "" {
+		return civogo.HTTPError{Code: 409, Status: "409 Conflict", Reason: "reserved IP is still assigned"}
+	}
+	delete(c.ips, id)
+	return nil
+}
+
+// AssignReservedIP attaches a reserved IP to an instance, replacing its
+// public IP.
+func (c *Client) AssignReservedIP(id, instanceID, region string) error {
+	if err := c.enter("AssignReservedIP"); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+
+	ip, ok := c.ips[id]
+	if !ok {
+		return ipNotFound(id)
+	}
+	i, ok := c.instances[instanceID]
+	if !ok {
+		return fmt.Errorf("%w: instance %s not found", civogo.DatabaseInstanceNotFoundError, instanceID)
+	}
+	if ip.AssignedTo.ID != "" {
+		return civogo.HTTPError{Code: 409, Status: "409 Conflict", Reason: "reserved IP is already assigned"}
+	}
+	ip.AssignedTo = civo.ReservedIPAssignee{ID: i.ID, Type: "instance", Name: i.Hostname}
+	i.PublicIP = ip.IP
+	return nil
+}
+
+// UnassignReservedIP detaches a reserved IP.
+func (c *Client) UnassignReservedIP(id, region string) error {
+	if err := c.enter("UnassignReservedIP"); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+
+	ip, ok := c.ips[id]
+	if !ok {
+		return ipNotFound(id)
+	}
+	if i, ok := c.instances[ip.AssignedTo.ID]; ok {
+		i.PublicIP = ""
+	}
+	ip.AssignedTo = civo.ReservedIPAssignee{}
+	return nil
+}
+
+func ipNotFound(id string) error {
+	return civogo.HTTPError{Code: 404, Status: "404 Not Found", Reason: fmt.Sprintf("reserved IP %s not found", id)}
+}
+
+// ListSSHKeys returns all public keys.
+func (c *Client) ListSSHKeys() ([]civogo.SSHKey, error) {
+	if err := c.enter("ListSSHKeys"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	var out []civogo.SSHKey
+	for _, k := range c.sshKeys {
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+// NewSSHKey uploads a public key in authorized_keys format.
+func (c *Client) NewSSHKey(name string, publicKey string) (*civogo.SimpleResponse, error) {
+	if err := c.enter("NewSSHKey"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	if publicKey == "" {
+		return nil, fmt.Errorf("%w: public key is empty", civogo.ParameterPublicKeyEmptyError)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", civogo.ParameterPublicKeyEmptyError, err)
+	}
+	id := c.nextID("sshkey")
+	c.sshKeys[id] = civogo.SSHKey{ID: id, Name: name, Fingerprint: ssh.FingerprintSHA256(key)}
+	return &civogo.SimpleResponse{ID: id, Result: civogo.ResultSuccess}, nil
+}
+
+// DeleteSSHKey removes a public key.
+func (c *Client) DeleteSSHKey(id string) (*civogo.SimpleResponse, error) {
+	if err := c.enter("DeleteSSHKey"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	if _, ok := c.sshKeys[id]; !ok {
+		return nil, fmt.Errorf("%w: ssh key %s not found", civogo.DatabaseSSHKeyNotFoundError, id)
+	}
+	delete(c.sshKeys, id)
+	return &civogo.SimpleResponse{ID: id, Result: civogo.ResultSuccess}, nil
+}
+
+// CreateSnapshot starts a snapshot of an instance in the pending state.
+// Like the PUT of the API, it overwrites a snapshot with the same name
+// in the region of the instance in place.
+func (c *Client) CreateSnapshot(name string, r *civogo.SnapshotConfig) (*civogo.Snapshot, error) {
+	if err := c.enter("CreateSnapshot"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	i, ok := c.instances[r.InstanceID]
+	if !ok {
+		return nil, fmt.Errorf("%w: instance %s not found", civogo.DatabaseInstanceNotFoundError, r.InstanceID)
+	}
+
+	var s *snapshot
+	for _, existing := range c.snapshots {
+		if existing.Name == name && existing.Region == i.Region {
+			s = existing
+			break
+		}
+	}
+	if s == nil {
+		s = &snapshot{Snapshot: civogo.Snapshot{ID: c.nextID("snapshot")}}
+		c.snapshots[s.ID] = s
+	}
+	s.Snapshot = civogo.Snapshot{
+		ID:          s.ID,
+		InstanceID:  i.ID,
+		Hostname:    i.Hostname,
+		Template:    i.TemplateID,
+		Region:      i.Region,
+		Name:        name,
+		State:       SnapshotPending,
+		RequestedAt: time.Now(),
+	}
+	s.polls = 0
+	c.history[s.ID] = append(c.history[s.ID], s.State)
+
+	out := s.Snapshot
+	return &out, nil
+}
+
+// ListSnapshots returns all snapshots, moving them along their
+// lifecycle.
+func (c *Client) ListSnapshots() ([]civogo.Snapshot, error) {
+	if err := c.enter("ListSnapshots"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	var out []civogo.Snapshot
+	for _, s := range c.snapshots {
+		c.poll(s)
+		out = append(out, s.Snapshot)
+	}
+	return out, nil
+}
+
+// FindSnapshot finds a snapshot by part of its ID or name, moving it
+// along its lifecycle.
+func (c *Client) FindSnapshot(search string) (*civogo.Snapshot, error) {
+	if err := c.enter("FindSnapshot"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	var found *snapshot
+	for _, s := range c.snapshots {
+		if strings.Contains(s.ID, search) || strings.Contains(s.Name, search) {
+			if found != nil {
+				return nil, fmt.Errorf("%w: unable to find %s because there were multiple matches", civogo.MultipleMatchesError, search)
+			}
+			found = s
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: unable to find %s, zero matches", civogo.ZeroMatchesError, search)
+	}
+
+	c.poll(found)
+
+	out := found.Snapshot
+	return &out, nil
+}
+
+// DeleteSnapshot removes a snapshot by ID.
+func (c *Client) DeleteSnapshot(id string) (*civogo.SimpleResponse, error) {
+	if err := c.enter("DeleteSnapshot"); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	if _, ok := c.snapshots[id]; !ok {
+		return nil, fmt.Errorf("%w: snapshot %s not found", civogo.DatabaseSnapshotNotFoundError, id)
+	}
+	delete(c.snapshots, id)
+	return &civogo.SimpleResponse{ID: id, Result: civogo.ResultSuccess}, nil
+}
+
+// enter records the call, applies any configured delay and fault, and
+// on success returns with c.mu held.
+func (c *Client) enter(method string) error {
+	c.mu.Lock()
+	c.calls = append(c.calls, method)
+	delay := c.delays[method]
+	c.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	c.mu.Lock()
+	if queue := c.faults[method]; len(queue) > 0 {
+		c.faults[method] = queue[1:]
+		if queue[0] != nil {
+			c.mu.Unlock()
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+// poll records a read of a snapshot, completing it after SnapshotPolls
+// reads.
+func (c *Client) poll(s *snapshot) {
+	s.polls++
+	if s.State == SnapshotPending && s.polls > c.SnapshotPolls {
+		s.State = SnapshotComplete
+		s.CompletedAt = time.Now()
+		c.history[s.ID] = append(c.history[s.ID], s.State)
+	}
+}
+
+// setStatus moves an instance to a new status, restarting the count of
+// reads until the next transition.
+func (c *Client) setStatus(i *instance, status string) {
+	i.polls = 0
+	if i.Status != status {
+		i.Status = status
+		c.history[i.ID] = append(c.history[i.ID], status)
+	}
+}
+
+func (c *Client) nextID(kind string) string {
+	c.seq++
+	return fmt.Sprintf("%s-%04d", kind, c.seq)
+}
@@ -0,0 +1,79 @@
+package civo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/civo/civogo"
+)
+
+// apiClient adds the endpoints civogo does not cover, such as reserved
+// IPs, to *civogo.Client.
+type apiClient struct {
+	*civogo.Client
+}
+
+var _ CivoAPI = (*apiClient)(nil)
+
+// NewClient returns a CivoAPI talking to the Civo API with token.
+func NewClient(token string) (CivoAPI, error) {
+	client, err := civogo.NewClient(token)
+	if err != nil {
+		return nil, err
+	}
+	return &apiClient{client}, nil
+}
+
+// ListSizes returns the instance sizes available in region, or in the
+// default region of the account when region is empty. civogo can not
+// pass a region to the sizes endpoint.
+func (c *apiClient) ListSizes(region string) ([]civogo.InstanceSize, error) {
+	path := "/v2/sizes"
+	if region != "" {
+		path += "?region=" + url.QueryEscape(region)
+	}
+	resp, err := c.SendGetRequest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make([]civogo.InstanceSize, 0)
+	if err := json.Unmarshal(resp, &sizes); err != nil {
+		return nil, fmt.Errorf("%w: %s", civogo.ResponseDecodeFailedError, err)
+	}
+	return sizes, nil
+}
+
+// The calls below are polled by the builder. civogo decodes every error
+// it gets, turning server errors, rate limiting and dropped connections
+// into UnknowError, so they are reimplemented here to return the
+// civogo.HTTPError or *url.Error as is and let the poller retry them.
+
+// GetInstance returns the instance with the given ID.
+func (c *apiClient) GetInstance(id string) (*civogo.Instance, error) {
+	resp, err := c.SendGetRequest("/v2/instances/" + url.PathEscape(id))
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &civogo.Instance{}
+	if err := json.Unmarshal(resp, instance); err != nil {
+		return nil, fmt.Errorf("%w: %s", civogo.ResponseDecodeFailedError, err)
+	}
+	return instance, nil
+}
+
+// ListSnapshots returns all snapshots.
+func (c *apiClient) ListSnapshots() ([]civogo.Snapshot, error) {
+	resp, err := c.SendGetRequest("/v2/snapshots")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]civogo.Snapshot, 0)
+	if err := json.Unmarshal(resp, &snapshots); err != nil {
+		return nil, fmt.Errorf("%w: %s", civogo.ResponseDecodeFailedError, err)
+	}
+	return snapshots, nil
+}
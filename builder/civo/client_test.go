@@ -0,0 +1,127 @@
+package civo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/civo/civogo"
+)
+
+func newTestAPIClient(t *testing.T, handler http.HandlerFunc) *apiClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := civogo.NewClientForTestingWithServer(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apiClient{client}
+}
+
+func TestAPIClientPolledCallsKeepTransientErrors(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"code":"internal_server_error","reason":"try again"}`))
+	})
+
+	_, err := client.GetInstance("instance-1")
+	if !isTransientError(err) {
+		t.Fatalf("GetInstance: %v is not retried", err)
+	}
+	_, err = client.ListSnapshots()
+	if !isTransientError(err) {
+		t.Fatalf("ListSnapshots: %v is not retried", err)
+	}
+}
+
+func TestAPIClientDroppedConnectionIsTransient(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	})
+
+	if _, err := client.GetInstance("instance-1"); !isTransientError(err) {
+		t.Fatalf("%v is not retried", err)
+	}
+}
+
+func TestAPIClientGetInstance(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/instances/instance-1":
+			w.Write([]byte(`{"id":"instance-1","status":"ACTIVE"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"database_instance_not_found"}`))
+		}
+	})
+
+	instance, err := client.GetInstance("instance-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.ID != "instance-1" || instance.Status != "ACTIVE" {
+		t.Fatalf("unexpected instance %+v", instance)
+	}
+
+	_, err = client.GetInstance("instance-2")
+	if !isNotFound(err) || isTransientError(err) {
+		t.Fatalf("%v should be a terminal not found error", err)
+	}
+}
+
+func TestAPIClientListReservedIPs(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/ips" || r.URL.Query().Get("region") != "LON1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Write([]byte(`{"page":1,"pages":2,"items":[{"id":"ip-1","name":"packer-1"}]}`))
+		case "2":
+			w.Write([]byte(`{"page":2,"pages":2,"items":[{"id":"ip-2","name":"packer-2","assigned_to":{"id":"instance-1"}}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	ips, err := client.ListReservedIPs("LON1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 2 || ips[0].ID != "ip-1" || ips[1].AssignedTo.ID != "instance-1" {
+		t.Fatalf("unexpected reserved IPs: %+v", ips)
+	}
+}
+
+func TestAPIClientListSizes(t *testing.T) {
+	client := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/sizes" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("region") {
+		case "LON1":
+			w.Write([]byte(`[{"id":"size-1","name":"g3.small","selectable":true},{"id":"size-2","name":"g3.xlarge","selectable":true}]`))
+		case "":
+			w.Write([]byte(`[{"id":"size-1","name":"g3.small","selectable":true}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	})
+
+	for region, want := range map[string]int{"LON1": 2, "": 1, "NYC1": 0} {
+		sizes, err := client.ListSizes(region)
+		if err != nil {
+			t.Fatalf("%q: %s", region, err)
+		}
+		if len(sizes) != want {
+			t.Fatalf("%q: got %d sizes, want %d: %+v", region, len(sizes), want, sizes)
+		}
+	}
+}
@@ -1,23 +1,30 @@
-//go:generate struct-markdown
-//go:generate mapstructure-to-hcl2 -type Config
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,SourceImageFilter
 
 package civo
 
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"regexp"
+	"strconv"
 	"time"
 
-	"github.com/hashicorp/packer/common"
-	"github.com/hashicorp/packer/common/uuid"
-	"github.com/hashicorp/packer/helper/communicator"
-	"github.com/hashicorp/packer/helper/config"
-	"github.com/hashicorp/packer/packer"
-	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/mitchellh/mapstructure"
 )
 
+// maxUserDataSize is the largest user data script accepted, the same
+// limit cloud-init based clouds commonly apply.
+const maxUserDataSize = 64 * 1024
+
 // Config to teh packer
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
@@ -33,16 +40,62 @@ type Config struct {
 	Size string `mapstructure:"size" required:"true"`
 	// The name (or slug) of the base image to use. This is the
 	// image that will be used to launch a new instance and provision it.
-	Template string `mapstructure:"template" required:"true"`
-	// Set to true to enable private networking
-	// for the instance being created. This defaults to true.
-	PublicNetworking string `mapstructure:"private_networking" required:"false"`
+	// Exactly one of `template`, `source_snapshot`, `source_image_id` or
+	// `source_image_filter` must be set.
+	Template string `mapstructure:"template" required:"false"`
+	// The exact name or ID of a snapshot, in `region`, to launch the instance
+	// from instead of a template. Useful to layer images on top of the
+	// result of a previous build.
+	SourceSnapshot string `mapstructure:"source_snapshot" required:"false"`
+	// The ID of a custom disk image to launch the instance from instead
+	// of a public template.
+	SourceImageID string `mapstructure:"source_image_id" required:"false"`
+	// Filters used to pick the template or snapshot to launch the instance
+	// from when the build runs, instead of naming it. See
+	// `SourceImageFilter` below.
+	SourceImageFilter SourceImageFilter `mapstructure:"source_image_filter" required:"false"`
+	// The ID of the private network to launch the instance in. Defaults to
+	// the default network of the account.
+	NetworkID string `mapstructure:"network_id" required:"false"`
+	// The label of the private network to launch the instance in.
+	// Mutually exclusive with `network_id`.
+	NetworkName string `mapstructure:"network_name" required:"false"`
+	// The ID of an existing firewall to apply to the instance.
+	FirewallID string `mapstructure:"firewall_id" required:"false"`
+	// Create a firewall for the duration of the build that only allows the
+	// communicator port (SSH or WinRM) from the public IP of the machine
+	// running Packer. Mutually exclusive with `firewall_id`.
+	TemporaryFirewall bool `mapstructure:"temporary_firewall" required:"false"`
+	// The CIDR blocks the temporary firewall allows the communicator port
+	// from, e.g. when Packer runs behind a NAT whose egress addresses are
+	// known. Defaults to the detected public IP of the machine running
+	// Packer.
+	TemporaryFirewallSourceCidrs []string `mapstructure:"temporary_firewall_source_cidrs" required:"false"`
+	// The address of the instance Packer connects to, `public` or
+	// `private`. Defaults to the public IP when the instance has one and to
+	// the private IP otherwise. Use `private` together with
+	// `ssh_bastion_host` to build inside a private network.
+	SSHInterface string `mapstructure:"ssh_interface" required:"false"`
+	// The public IP of the instance: `create` to give it a new one, `none`
+	// to give it none, or the ID of a reserved IP to attach. Defaults to
+	// `create`.
+	PublicIP string `mapstructure:"public_ip" required:"false"`
+	// A reserved IP to attach to the instance once it is active and to
+	// connect to, so that provisioners reach the outside world from a
+	// fixed address. Either the ID of an existing reserved IP, or `create`
+	// to allocate one for the build and release it afterwards. It is
+	// detached before the instance is destroyed.
+	ReservedIP string `mapstructure:"reserved_ip" required:"false"`
+	// Deprecated: use `public_ip`. Setting it to true builds without a
+	// public IP, like `public_ip = "none"`.
+	PrivateNetworking string `mapstructure:"private_networking" required:"false"`
 	// The name of the resulting snapshot that will
 	// appear in your account. Defaults to `packer-{{timestamp}}` (see
 	// configuration templates for more info).
 	SnapshotName string `mapstructure:"snapshot_name" required:"false"`
-	// The regions of the resulting
-	// snapshot that will appear in your account.
+	// The regions of the resulting snapshot that will appear in your
+	// account. Civo cannot copy snapshots between regions, so this may only
+	// list the build `region`, which is also the default.
 	SnapshotRegions []string `mapstructure:"snapshot_regions" required:"false"`
 	// The time to wait, as a duration string, for a
 	// instance to enter a desired state (such as "active") before timing out. The
@@ -58,6 +111,42 @@ type Config struct {
 	SnapshotTimeout time.Duration `mapstructure:"snapshot_timeout" required:"false"`
 	// The name assigned to the instance. Civo sets the hostname of the machine to this value.
 	InstanceName string `mapstructure:"instance_name" required:"false"`
+	// User data to launch with the instance, e.g. a shell or cloud-init
+	// script run on first boot, before Packer connects. Packer template
+	// functions are interpolated in it.
+	UserData string `mapstructure:"user_data" required:"false"`
+	// Path to a file containing the user data. Mutually exclusive with
+	// `user_data`. Template functions are interpolated in its contents
+	// too.
+	UserDataFile string `mapstructure:"user_data_file" required:"false"`
+	// Tags to apply to the build instance. Civo tags can not contain
+	// spaces. Besides the usual template functions, `{{ .BuildName }}`,
+	// `{{ .SourceImage }}`, `{{ .SourceImageID }}` and `{{ .Region }}` are
+	// available.
+	InstanceTags []string `mapstructure:"instance_tags" required:"false"`
+
+	// Run the provisioners and tear the instance down without creating a
+	// snapshot, e.g. to test provisioning. The artifact then has no
+	// snapshot.
+	SkipCreateImage bool `mapstructure:"skip_create_image" required:"false"`
+	// Replace an existing snapshot with the same name as `snapshot_name`
+	// in `region` instead of failing the build, like `packer build -force`.
+	// Civo overwrites the old snapshot in place when the new one is taken,
+	// so a build failing from then on loses it.
+	ForceDeleteSnapshot bool `mapstructure:"force_delete_snapshot" required:"false"`
+	// Delete the resources a previous, killed run of the same build left
+	// behind without asking. Packer records the resources of every build
+	// in its cache directory until they are deleted, and otherwise asks
+	// whether to delete them when the build is run again.
+	CleanupPreviousRun bool `mapstructure:"cleanup_previous_run" required:"false"`
+	// Keep the private key saved in debug mode (`civo_<build name>.pem`)
+	// after the build instead of deleting it.
+	KeepDebugKey bool `mapstructure:"keep_debug_key" required:"false"`
+	// A command run on the instance after provisioning and before it is
+	// shut down, to generalise it so that instances launched from the
+	// snapshot get a fresh identity. For Windows images this is typically
+	// `C:\Windows\System32\Sysprep\sysprep.exe /generalize /oobe /quit /mode:vm`.
+	GeneralizeCommand string `mapstructure:"generalize_command" required:"false"`
 
 	ctx interpolate.Context
 }
@@ -73,6 +162,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"run_command",
+				"instance_tags",
 			},
 		},
 	}, raws...)
@@ -97,7 +187,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 
 	if c.InstanceName == "" {
 		// Default to packer-[time-ordered-uuid]
-		c.InstanceName = fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
+		c.InstanceName = resourceName()
 	}
 
 	if c.StateTimeout == 0 {
@@ -111,40 +201,180 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.SnapshotTimeout = 60 * time.Minute
 	}
 
-	if c.PublicNetworking == "" {
-		c.PublicNetworking = "true"
+	if len(c.SnapshotRegions) == 0 {
+		c.SnapshotRegions = []string{c.Region}
 	}
 
-	var errs *packer.MultiError
+	var warnings []string
+	var errs *packersdk.MultiError
+
+	// Before private_networking is mapped to it
+	publicIPSet := c.PublicIP != ""
+	if c.PrivateNetworking != "" {
+		warnings = append(warnings, "private_networking is deprecated, use public_ip instead")
+		private, err := strconv.ParseBool(c.PrivateNetworking)
+		switch {
+		case err != nil:
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+				"private_networking must be true or false, not %q", c.PrivateNetworking))
+		case c.PublicIP != "":
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("only one of public_ip or private_networking can be specified"))
+		case private:
+			c.PublicIP = "none"
+		default:
+			c.PublicIP = "create"
+		}
+	}
+	if c.ReservedIP != "" {
+		if c.ReservedIP != "create" && !civoIDRegexp.MatchString(c.ReservedIP) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+				"reserved_ip must be create or the ID of a reserved IP, not %q", c.ReservedIP))
+		}
+		switch {
+		case publicIPSet:
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("only one of public_ip or reserved_ip can be specified"))
+		case c.PrivateNetworking != "":
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("only one of private_networking or reserved_ip can be specified"))
+		}
+		// The reserved IP replaces the one the instance is created with
+		c.PublicIP = "none"
+	}
+	if c.PublicIP == "" {
+		c.PublicIP = "create"
+	}
+	switch {
+	case c.PublicIP == "create", c.PublicIP == "none":
+	case civoIDRegexp.MatchString(c.PublicIP):
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+			"public_ip must be create, none or the ID of a reserved IP, not %q", c.PublicIP))
+	}
 
 	if es := c.Comm.Prepare(&c.ctx); len(es) > 0 {
-		errs = packer.MultiErrorAppend(errs, es...)
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+	if c.Comm.Type == "ssh" {
+		es := prepareTemporaryKeyPair(&c.Comm.SSHTemporaryKeyPairType, &c.Comm.SSHTemporaryKeyPairBits)
+		errs = packersdk.MultiErrorAppend(errs, es...)
+
+		if c.Comm.SSHKeyPairName != "" && c.Comm.SSHPrivateKeyFile == "" && !c.Comm.SSHAgentAuth {
+			errs = packersdk.MultiErrorAppend(errs, errors.New(
+				"ssh_private_key_file or ssh_agent_auth must be specified when ssh_keypair_name is"))
+		}
 	}
 	if c.APIToken == "" {
 		// Required configurations that will display errors if not set
-		errs = packer.MultiErrorAppend(
+		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("api_token for auth must be specified"))
 	}
 
 	if c.Region == "" {
-		errs = packer.MultiErrorAppend(
+		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("region is required"))
 	}
 
 	if c.Size == "" {
-		errs = packer.MultiErrorAppend(
+		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("size is required"))
 	}
 
-	if c.Template == "" {
-		errs = packer.MultiErrorAppend(
-			errs, errors.New("template is required"))
+	sources := 0
+	for _, source := range []string{c.Template, c.SourceSnapshot, c.SourceImageID} {
+		if source != "" {
+			sources++
+		}
+	}
+	if !c.SourceImageFilter.Empty() {
+		sources++
+		if es := c.SourceImageFilter.Prepare(); len(es) > 0 {
+			errs = packersdk.MultiErrorAppend(errs, es...)
+		}
+	}
+	if sources == 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New(
+			"one of template, source_snapshot, source_image_id or source_image_filter is required"))
+	}
+	if sources > 1 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New(
+			"only one of template, source_snapshot, source_image_id or source_image_filter may be set"))
+	}
+
+	if c.NetworkID != "" && c.NetworkName != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("only one of network_id or network_name can be specified"))
+	}
+
+	if c.FirewallID != "" && c.TemporaryFirewall {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("only one of firewall_id or temporary_firewall can be specified"))
+	}
+	if len(c.TemporaryFirewallSourceCidrs) > 0 && !c.TemporaryFirewall {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("temporary_firewall_source_cidrs requires temporary_firewall"))
+	}
+	for _, cidr := range c.TemporaryFirewallSourceCidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("temporary_firewall_source_cidrs: %q is not a valid CIDR block", cidr))
+		}
+	}
+
+	switch c.SSHInterface {
+	case "", "public", "private":
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+			"ssh_interface must be one of public or private, not %q", c.SSHInterface))
+	}
+
+	if c.UserData != "" && c.UserDataFile != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("only one of user_data or user_data_file can be specified"))
+	} else if c.UserDataFile != "" {
+		data, err := ioutil.ReadFile(c.UserDataFile)
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("user_data_file: %s", err))
+		} else if c.UserData, err = interpolate.Render(string(data), &c.ctx); err != nil {
+			// Interpolated like user_data, which the decoder renders
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("user_data_file: %s", err))
+		}
+	}
+	if len(c.UserData) > maxUserDataSize {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+			"user data is %d bytes, it must not be larger than %d bytes", len(c.UserData), maxUserDataSize))
+	}
+
+	for _, region := range c.SnapshotRegions {
+		if region != c.Region {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+				"snapshot_regions: cannot make the snapshot available in %q, "+
+					"the Civo API does not support copying snapshots between regions", region))
+		}
 	}
 
 	if errs != nil && len(errs.Errors) > 0 {
-		return nil, errs
+		return warnings, errs
 	}
 
-	packer.LogSecretFilter.Set(c.APIToken)
-	return nil, nil
+	packersdk.LogSecretFilter.Set(c.APIToken)
+	return warnings, nil
+}
+
+// civoIDRegexp matches the UUIDs Civo identifies resources with.
+var civoIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// reservedIPID returns the ID of the existing reserved IP to attach to
+// the instance, if any.
+func (c *Config) reservedIPID() string {
+	if c.ReservedIP != "" && c.ReservedIP != "create" {
+		return c.ReservedIP
+	}
+	if c.PublicIP == "create" || c.PublicIP == "none" {
+		return ""
+	}
+	return c.PublicIP
 }
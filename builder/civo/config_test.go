@@ -0,0 +1,170 @@
+package civo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testConfig(overrides map[string]interface{}) map[string]interface{} {
+	raw := map[string]interface{}{
+		"api_token":    "token",
+		"region":       "LON1",
+		"size":         "g3.small",
+		"template":     "debian-buster",
+		"communicator": "none",
+	}
+	for k, v := range overrides {
+		raw[k] = v
+	}
+	return raw
+}
+
+func TestConfigPublicIP(t *testing.T) {
+	const reservedIP = "2b8d1f6e-44a4-4c3b-9a2b-0b5e3f6c7d8e"
+
+	cases := []struct {
+		name      string
+		overrides map[string]interface{}
+		publicIP  string
+		err       string
+	}{
+		{name: "default", publicIP: "create"},
+		{name: "none", overrides: map[string]interface{}{"public_ip": "none"}, publicIP: "none"},
+		{name: "reserved IP", overrides: map[string]interface{}{"public_ip": reservedIP}, publicIP: reservedIP},
+		{name: "invalid", overrides: map[string]interface{}{"public_ip": "yes"}, err: "public_ip must be"},
+		{name: "private networking", overrides: map[string]interface{}{"private_networking": "true"}, publicIP: "none"},
+		{name: "public networking", overrides: map[string]interface{}{"private_networking": "false"}, publicIP: "create"},
+		{
+			name:      "private networking and public_ip",
+			overrides: map[string]interface{}{"private_networking": "true", "public_ip": "none"},
+			err:       "only one of public_ip or private_networking",
+		},
+		{
+			name:      "reserved_ip",
+			overrides: map[string]interface{}{"reserved_ip": "create"},
+			publicIP:  "none",
+		},
+		{
+			name:      "reserved_ip and public_ip",
+			overrides: map[string]interface{}{"reserved_ip": "create", "public_ip": "create"},
+			err:       "only one of public_ip or reserved_ip",
+		},
+		{
+			name:      "reserved_ip and private networking",
+			overrides: map[string]interface{}{"reserved_ip": reservedIP, "private_networking": "true"},
+			err:       "only one of private_networking or reserved_ip",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c Config
+			_, err := c.Prepare(testConfig(tc.overrides))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				if strings.Contains(tc.err, "private_networking") && strings.Contains(err.Error(), "public_ip or reserved_ip") {
+					t.Fatalf("private_networking reported as public_ip: %s", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.PublicIP != tc.publicIP {
+				t.Fatalf("public_ip is %q, want %q", c.PublicIP, tc.publicIP)
+			}
+		})
+	}
+}
+
+func TestConfigUserData(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	script := writeFile("script.sh", "#!/bin/sh\necho {{user `greeting`}}\n")
+	largest := writeFile("largest.sh", strings.Repeat("x", maxUserDataSize))
+	tooLarge := writeFile("too-large.sh", strings.Repeat("x", maxUserDataSize+1))
+	invalid := writeFile("invalid.sh", "echo {{nope}}\n")
+
+	cases := []struct {
+		name      string
+		overrides map[string]interface{}
+		userData  string
+		err       string
+	}{
+		{name: "none"},
+		{
+			name:      "inline",
+			overrides: map[string]interface{}{"user_data": "#!/bin/sh\necho {{user `greeting`}}\n"},
+			userData:  "#!/bin/sh\necho hello\n",
+		},
+		{
+			name:      "file",
+			overrides: map[string]interface{}{"user_data_file": script},
+			userData:  "#!/bin/sh\necho hello\n",
+		},
+		{
+			name:      "both",
+			overrides: map[string]interface{}{"user_data": "echo hello", "user_data_file": script},
+			err:       "only one of user_data or user_data_file can be specified",
+		},
+		{
+			name:      "missing file",
+			overrides: map[string]interface{}{"user_data_file": filepath.Join(dir, "missing.sh")},
+			err:       "user_data_file: open",
+		},
+		{
+			name:      "invalid template in file",
+			overrides: map[string]interface{}{"user_data_file": invalid},
+			err:       "user_data_file: ",
+		},
+		{
+			name:      "largest file",
+			overrides: map[string]interface{}{"user_data_file": largest},
+			userData:  strings.Repeat("x", maxUserDataSize),
+		},
+		{
+			name:      "file too large",
+			overrides: map[string]interface{}{"user_data_file": tooLarge},
+			err:       "user data is 65537 bytes, it must not be larger than 65536 bytes",
+		},
+		{
+			name:      "inline too large",
+			overrides: map[string]interface{}{"user_data": strings.Repeat("x", maxUserDataSize+1)},
+			err:       "user data is 65537 bytes",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			overrides := map[string]interface{}{
+				"packer_user_variables": map[string]string{"greeting": "hello"},
+			}
+			for k, v := range tc.overrides {
+				overrides[k] = v
+			}
+
+			var c Config
+			_, err := c.Prepare(testConfig(overrides))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.UserData != tc.userData {
+				t.Fatalf("user data is %q, want %q", c.UserData, tc.userData)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package civo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// sshDebugPauseFn wraps the pause of a debug run, so that once the
+// instance has an IP, every pause shows how to ssh into it.
+func sshDebugPauseFn(ui packersdk.Ui, c *Config, next multistep.DebugPauseFn) multistep.DebugPauseFn {
+	return func(loc multistep.DebugLocation, name string, state multistep.StateBag) {
+		if loc == multistep.DebugLocationAfterRun {
+			if cmd := sshDebugCommand(c, state); cmd != "" {
+				ui.Message(fmt.Sprintf("To connect to the instance: %s", cmd))
+			}
+		}
+		next(loc, name, state)
+	}
+}
+
+// onErrorUi is the UI given to the step runner. With -on-error=ask, it
+// shows how to ssh into the instance before asking what to do about a
+// failed step.
+//
+// The runner only asks questions after a step failed, which sets
+// "error", or in the pauses of -debug, which print the command anyway.
+// The wording of the question is not relied upon, it is up to the SDK.
+type onErrorUi struct {
+	packersdk.Ui
+	config *Config
+	state  multistep.StateBag
+}
+
+func (u *onErrorUi) Ask(query string) (string, error) {
+	if _, failed := u.state.GetOk("error"); failed && u.config.PackerOnError == "ask" {
+		if cmd := sshDebugCommand(u.config, u.state); cmd != "" {
+			u.Ui.Message(fmt.Sprintf("To debug the instance before answering: %s", cmd))
+		}
+	}
+	return u.Ui.Ask(query)
+}
+
+// sshDebugCommand returns a ready to paste ssh command line for the
+// instance, or "" when not known yet.
+func sshDebugCommand(c *Config, state multistep.StateBag) string {
+	ip, ok := state.GetOk("instance_ip")
+	if !ok || c.Comm.Type != "ssh" {
+		return ""
+	}
+
+	args := []string{"ssh"}
+	if path, ok := state.GetOk("debug_key_path"); ok {
+		args = append(args, "-i", path.(string))
+	} else if c.Comm.SSHPrivateKeyFile != "" {
+		args = append(args, "-i", c.Comm.SSHPrivateKeyFile)
+	}
+	if c.Comm.SSHPort != 0 && c.Comm.SSHPort != 22 {
+		args = append(args, "-p", fmt.Sprint(c.Comm.SSHPort))
+	}
+	if c.Comm.SSHBastionHost != "" {
+		jump := fmt.Sprintf("%s:%d", c.Comm.SSHBastionHost, c.Comm.SSHBastionPort)
+		if c.Comm.SSHBastionUsername != "" {
+			jump = c.Comm.SSHBastionUsername + "@" + jump
+		}
+		args = append(args, "-J", jump)
+	}
+	args = append(args, fmt.Sprintf("%s@%s", c.Comm.SSHUsername, ip))
+
+	return strings.Join(args, " ")
+}
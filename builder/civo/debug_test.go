@@ -0,0 +1,50 @@
+package civo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestOnErrorUiAsk(t *testing.T) {
+	cases := []struct {
+		name     string
+		onError  string
+		failed   bool
+		wantHint bool
+	}{
+		{"step failed", "ask", true, true},
+		{"debug pause", "ask", false, false},
+		{"other on-error", "abort", true, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{}
+			c.PackerOnError = tc.onError
+			c.Comm.Type = "ssh"
+			c.Comm.SSHUsername = "root"
+
+			state := new(multistep.BasicStateBag)
+			state.Put("instance_ip", "192.0.2.1")
+			state.Put("debug_key_path", "civo_test.pem")
+			if tc.failed {
+				state.Put("error", errors.New("step failed"))
+			}
+
+			mock := &packersdk.MockUi{}
+			ui := &onErrorUi{Ui: mock, config: c, state: state}
+
+			if _, err := ui.Ask("What now?"); err != nil || mock.AskQuery != "What now?" {
+				t.Fatalf("question not passed on: %q, %v", mock.AskQuery, err)
+			}
+			hint := strings.Contains(mock.MessageMessage, "ssh -i civo_test.pem root@192.0.2.1")
+			if hint != tc.wantHint {
+				t.Fatalf("hint shown: %v, want %v (message: %q)", hint, tc.wantHint, mock.MessageMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package civo
+
+import (
+	"testing"
+	"time"
+)
+
+// This file gives the tests of the civo_test package, which can import
+// civofake without an import cycle, access to the steps of the builder.
+
+type (
+	StepCheckJournal      = stepCheckJournal
+	StepCheckSnapshotName = stepCheckSnapshotName
+	StepCreateSSHKey      = stepCreateSSHKey
+	StepCreateFirewall    = stepCreateFirewall
+	StepCreateInstance    = stepCreateInstance
+	StepAttachReservedIP  = stepAttachReservedIP
+	StepInstanceInfo      = stepInstanceInfo
+	StepShutdown          = stepShutdown
+	StepPowerOff          = stepPowerOff
+	StepSnapshot          = stepSnapshot
+)
+
+var FindSourceImage = findSourceImage
+
+func NewStepSnapshot(timeout time.Duration) *StepSnapshot {
+	return &stepSnapshot{snapshotTimeout: timeout}
+}
+
+// UseFakeClock makes the pollers of the test fire at once, and returns
+// the clock to inspect the time spent waiting.
+func UseFakeClock(t testing.TB) *fakeClock {
+	clk := &fakeClock{now: time.Now()}
+	old := defaultClock
+	defaultClock = clk
+	t.Cleanup(func() { defaultClock = old })
+	return clk
+}
+
+// Elapsed returns the time the pollers waited for.
+func (c *fakeClock) Elapsed() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var total time.Duration
+	for _, d := range c.delays {
+		total += d
+	}
+	return total
+}
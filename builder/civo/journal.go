@@ -0,0 +1,277 @@
+package civo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/civo/civogo"
+	"github.com/gofrs/flock"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Kinds of resources created by the builder.
+const (
+	kindInstance   = "instance"
+	kindReservedIP = "reserved ip"
+	kindSSHKey     = "ssh key"
+	kindFirewall   = "firewall"
+)
+
+// kindOrder is the order resources have to be deleted in: instances
+// use everything else.
+var kindOrder = map[string]int{
+	kindInstance:   0,
+	kindReservedIP: 1,
+	kindSSHKey:     2,
+	kindFirewall:   3,
+}
+
+// journalEntry is a resource created by a build.
+type journalEntry struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Region    string    `json:"region"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (e journalEntry) String() string {
+	return fmt.Sprintf("%s %s (ID: %s)", e.Kind, e.Name, e.ID)
+}
+
+// journal records on disk every resource a build creates, from right
+// after its creation until it is deleted. The resources of a build that
+// is killed before cleaning up stay in the journal, so the next run of
+// the same build can delete them.
+//
+// The journal is locked for as long as the build runs, so that another
+// run of the same build, on this machine, neither overwrites it nor
+// deletes the resources in use.
+type journal struct {
+	path string
+	lock *flock.Flock
+
+	mu      sync.Mutex
+	Entries []journalEntry `json:"resources"`
+}
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// errJournalLocked is returned by openJournal while another run of the
+// build holds the journal.
+var errJournalLocked = errors.New("another run of this build is in progress")
+
+// openJournal locks and loads the journal of a build from the Packer
+// cache directory, or returns an empty one. It has to be closed once the
+// build is over.
+func openJournal(buildName string) (*journal, error) {
+	if buildName == "" {
+		buildName = "civo"
+	}
+	name := unsafePathChars.ReplaceAllString(buildName, "_")
+	path, err := packersdk.CachePath("civo", "journal", name+".json")
+	if err != nil {
+		return nil, err
+	}
+
+	lock := flock.New(filepath.Join(filepath.Dir(path), name+".lock"))
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, errJournalLocked
+	}
+
+	j := &journal{path: path, lock: lock}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, j)
+		if err != nil {
+			err = fmt.Errorf("%s is corrupt: %s", path, err)
+		}
+	}
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	return j, nil
+}
+
+// close unlocks the journal.
+func (j *journal) close() error {
+	return j.lock.Unlock()
+}
+
+// add records a newly created resource.
+func (j *journal) add(e journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	j.Entries = append(j.Entries, e)
+	return j.save()
+}
+
+// remove forgets a deleted resource.
+func (j *journal) remove(kind, id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i, e := range j.Entries {
+		if e.Kind == kind && e.ID == id {
+			j.Entries = append(j.Entries[:i], j.Entries[i+1:]...)
+			return j.save()
+		}
+	}
+	return nil
+}
+
+// entries returns the recorded resources, in the order they have to be
+// deleted in.
+func (j *journal) entries() []journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := append([]journalEntry(nil), j.Entries...)
+	sort.SliceStable(out, func(a, b int) bool {
+		return kindOrder[out[a].Kind] < kindOrder[out[b].Kind]
+	})
+	return out
+}
+
+// save atomically writes the journal, or removes the file when there is
+// nothing left in it.
+func (j *journal) save() error {
+	if len(j.Entries) == 0 {
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(j.path), "."+filepath.Base(j.path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		// The journal is only useful if it survives a crash
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, j.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// journalAdd records a resource in the journal of the build. Failing to
+// do so is not fatal, the resource is still cleaned up as usual.
+func journalAdd(state multistep.StateBag, e journalEntry) {
+	j, ok := state.Get("journal").(*journal)
+	if !ok {
+		return
+	}
+	if err := j.add(e); err != nil {
+		ui := state.Get("ui").(packersdk.Ui)
+		ui.Error(fmt.Sprintf("Error recording %s in %s: %s", e, j.path, err))
+	}
+}
+
+// journalRemove forgets a deleted resource.
+func journalRemove(state multistep.StateBag, kind, id string) {
+	j, ok := state.Get("journal").(*journal)
+	if !ok {
+		return
+	}
+	if err := j.remove(kind, id); err != nil {
+		log.Printf("Error removing %s %s from %s: %s", kind, id, j.path, err)
+	}
+}
+
+// deleteResource deletes a resource created by the builder.
+func deleteResource(client CivoAPI, kind, id, region string) error {
+	var err error
+	switch kind {
+	case kindInstance:
+		_, err = client.DeleteInstance(id)
+	case kindReservedIP:
+		err = client.DeleteReservedIP(id, region)
+	case kindSSHKey:
+		_, err = client.DeleteSSHKey(id)
+	case kindFirewall:
+		_, err = client.DeleteFirewall(id)
+	default:
+		err = fmt.Errorf("unknown resource kind %q", kind)
+	}
+	return err
+}
+
+// deleteLeftover deletes a resource a build left behind, treating one
+// that no longer exists as deleted. Reserved IPs are unassigned first,
+// as Civo refuses to delete them while attached, and firewalls are
+// retried until the instances using them are gone.
+func deleteLeftover(ctx context.Context, client CivoAPI, kind, id, region string, timeout time.Duration) error {
+	var err error
+	switch kind {
+	case kindFirewall:
+		err = deleteFirewall(ctx, client, id, timeout)
+	case kindReservedIP:
+		var ip *ReservedIP
+		ip, err = client.GetReservedIP(id, region)
+		if err == nil && ip.AssignedTo.ID != "" {
+			err = client.UnassignReservedIP(id, region)
+		}
+		if err == nil {
+			err = deleteResource(client, kind, id, region)
+		}
+	default:
+		err = deleteResource(client, kind, id, region)
+	}
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// isNotFound reports whether err means the resource does not exist,
+// e.g. because it was already deleted.
+func isNotFound(err error) bool {
+	var httpErr civogo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code == 404
+	}
+	return errors.Is(err, civogo.DatabaseInstanceNotFoundError) ||
+		errors.Is(err, civogo.DatabaseSSHKeyNotFoundError) ||
+		errors.Is(err, civogo.DatabaseFirewallNotFoundError) ||
+		errors.Is(err, civogo.ZeroMatchesError)
+}
@@ -0,0 +1,45 @@
+package civo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// publicIPURL answers with the public IPv4 address of the caller as
+// plain text.
+var publicIPURL = "https://api.ipify.org"
+
+// detectPublicIP returns the IPv4 address the machine running Packer
+// connects to the instance from.
+func detectPublicIP(ctx context.Context) (net.IP, error) {
+	req, err := http.NewRequest("GET", publicIPURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Error detecting public IP: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error detecting public IP: %s returned %s", publicIPURL, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error detecting public IP: %s", err)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || ip.To4() == nil {
+		return nil, fmt.Errorf("Error detecting public IP: %q is not an IPv4 address", body)
+	}
+
+	return ip, nil
+}
@@ -0,0 +1,188 @@
+package civo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// BuilderTag is added to every instance the builder creates, so that
+// instances left behind by killed builds can be found again.
+const BuilderTag = "packer-civo"
+
+// resourceNameRegexp matches the names given by resourceName. The first
+// part of a time ordered UUID is the creation time in hex.
+var resourceNameRegexp = regexp.MustCompile(`^packer-([0-9a-f]{8})-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// resourceName returns a unique name for a temporary resource, which
+// records when it was created.
+func resourceName() string {
+	return fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
+}
+
+// resourceCreatedAt returns the creation time recorded in a name given
+// by resourceName.
+func resourceCreatedAt(name string) (time.Time, bool) {
+	m := resourceNameRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	unix, err := strconv.ParseUint(m[1], 16, 32)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(unix), 0), true
+}
+
+// Orphan is a resource created by a build that never cleaned it up.
+type Orphan struct {
+	Kind      string
+	ID        string
+	Name      string
+	Region    string
+	CreatedAt time.Time
+}
+
+func (o Orphan) String() string {
+	return fmt.Sprintf("%s %s (ID: %s, created %s)", o.Kind, o.Name, o.ID, o.CreatedAt.Format(time.RFC3339))
+}
+
+// Reaper finds and deletes the instances, reserved IPs, SSH keys and
+// firewalls left behind by builds that were killed before they could
+// clean up.
+type Reaper struct {
+	Client CivoAPI
+	// Only resources created longer ago than this are considered orphaned,
+	// so that running builds are left alone.
+	OlderThan time.Duration
+	// Report what would be deleted without deleting anything.
+	DryRun bool
+	// How long to keep trying to delete a firewall whose instances are
+	// still being destroyed. Defaults to 5 minutes.
+	Timeout time.Duration
+	// Where the report is written to.
+	Out io.Writer
+
+	now func() time.Time
+}
+
+// Find returns the resources created by the builder longer than
+// OlderThan ago.
+func (r *Reaper) Find() ([]Orphan, error) {
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	cutoff := now().Add(-r.OlderThan)
+
+	var orphans []Orphan
+
+	instances, err := r.Client.ListAllInstances()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing instances: %s", err)
+	}
+	for _, i := range instances {
+		if hasTag(i.Tags, BuilderTag) && i.CreatedAt.Before(cutoff) {
+			orphans = append(orphans, Orphan{Kind: kindInstance, ID: i.ID, Name: i.Hostname, CreatedAt: i.CreatedAt})
+		}
+	}
+
+	// Reserved IPs are per region
+	regions, err := r.Client.ListRegions()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing regions: %s", err)
+	}
+	for _, region := range regions {
+		ips, err := r.Client.ListReservedIPs(region.Code)
+		if err != nil {
+			return nil, fmt.Errorf("Error listing reserved IPs in region %s: %s", region.Code, err)
+		}
+		for _, ip := range ips {
+			if created, ok := resourceCreatedAt(ip.Name); ok && created.Before(cutoff) {
+				orphans = append(orphans, Orphan{
+					Kind:      kindReservedIP,
+					ID:        ip.ID,
+					Name:      ip.Name,
+					Region:    region.Code,
+					CreatedAt: created,
+				})
+			}
+		}
+	}
+
+	keys, err := r.Client.ListSSHKeys()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing ssh keys: %s", err)
+	}
+	for _, k := range keys {
+		if created, ok := resourceCreatedAt(k.Name); ok && created.Before(cutoff) {
+			orphans = append(orphans, Orphan{Kind: kindSSHKey, ID: k.ID, Name: k.Name, CreatedAt: created})
+		}
+	}
+
+	firewalls, err := r.Client.ListFirewalls()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing firewalls: %s", err)
+	}
+	for _, f := range firewalls {
+		if created, ok := resourceCreatedAt(f.Name); ok && created.Before(cutoff) {
+			orphans = append(orphans, Orphan{Kind: kindFirewall, ID: f.ID, Name: f.Name, CreatedAt: created})
+		}
+	}
+
+	return orphans, nil
+}
+
+// Reap deletes the orphaned resources, instances first since they use
+// everything else, and reports on each of them. It carries on
+// after a failed deletion and returns the last error.
+func (r *Reaper) Reap() error {
+	orphans, err := r.Find()
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Fprintln(r.Out, "No orphaned resources found")
+		return nil
+	}
+
+	var lastErr error
+	for _, o := range orphans {
+		if r.DryRun {
+			fmt.Fprintf(r.Out, "Would delete %s\n", o)
+			continue
+		}
+
+		if err := r.delete(o); err != nil {
+			lastErr = fmt.Errorf("Error deleting %s: %s", o, err)
+			fmt.Fprintln(r.Out, lastErr)
+			continue
+		}
+		fmt.Fprintf(r.Out, "Deleted %s\n", o)
+	}
+
+	return lastErr
+}
+
+func (r *Reaper) delete(o Orphan) error {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+	return deleteLeftover(context.Background(), r.Client, o.Kind, o.ID, o.Region, timeout)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package civo_test
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+)
+
+// nameAt returns a resource name as given by the builder at t.
+func nameAt(t time.Time) string {
+	return fmt.Sprintf("packer-%08x-0000-4000-8000-000000000000", t.Unix())
+}
+
+func TestReaper(t *testing.T) {
+	clk := civo.UseFakeClock(t)
+	client := civofake.NewClient()
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+
+	fw, err := client.NewFirewall(nameAt(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan := client.AddInstance(civogo.Instance{
+		Hostname:   nameAt(old),
+		Tags:       []string{civo.BuilderTag},
+		CreatedAt:  old,
+		FirewallID: fw.ID,
+	})
+	client.AddInstance(civogo.Instance{Hostname: "web", CreatedAt: old})
+	client.AddReservedIP(civo.ReservedIP{
+		Name:       nameAt(old),
+		AssignedTo: civo.ReservedIPAssignee{ID: orphan.ID, Type: "instance"},
+	})
+	userIP := client.AddReservedIP(civo.ReservedIP{Name: "web"})
+	recentIP := client.AddReservedIP(civo.ReservedIP{Name: nameAt(recent)})
+	client.AddSSHKey(civogo.SSHKey{Name: nameAt(old)})
+
+	// The instance is still being destroyed when the firewall goes
+	client.FailNext("DeleteFirewall", civogo.DatabaseFirewallDeleteFailedError)
+
+	var out bytes.Buffer
+	reaper := &civo.Reaper{
+		Client:    client,
+		OlderThan: 24 * time.Hour,
+		DryRun:    true,
+		Out:       &out,
+	}
+
+	orphans, err := reaper.Find()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, o := range orphans {
+		kinds = append(kinds, o.Kind)
+	}
+	want := []string{"instance", "reserved ip", "ssh key", "firewall"}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("found %v, want %v", kinds, want)
+	}
+
+	if err := reaper.Reap(); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "Would delete"); n != 4 {
+		t.Fatalf("expected 4 resources in the dry run report, got:\n%s", out.String())
+	}
+	if len(client.Instances()) != 2 || len(client.ReservedIPs()) != 3 || len(client.SSHKeys()) != 1 {
+		t.Fatal("the dry run deleted resources")
+	}
+
+	out.Reset()
+	reaper.DryRun = false
+	if err := reaper.Reap(); err != nil {
+		t.Fatalf("reap failed: %s\n%s", err, out.String())
+	}
+
+	instances := client.Instances()
+	if len(instances) != 1 || instances[0].Hostname != "web" {
+		t.Fatalf("instances left: %+v", instances)
+	}
+	var ips []string
+	for _, ip := range client.ReservedIPs() {
+		ips = append(ips, ip.ID)
+	}
+	sort.Strings(ips)
+	wantIPs := []string{userIP.ID, recentIP.ID}
+	sort.Strings(wantIPs)
+	if strings.Join(ips, ",") != strings.Join(wantIPs, ",") {
+		t.Fatalf("reserved IPs left: %v, want %v", ips, wantIPs)
+	}
+	if keys := client.SSHKeys(); len(keys) != 0 {
+		t.Fatalf("ssh keys left: %+v", keys)
+	}
+	firewalls, _ := client.ListFirewalls()
+	if len(firewalls) != 0 {
+		t.Fatalf("firewalls left: %+v", firewalls)
+	}
+	if clk.Elapsed() == 0 {
+		t.Fatal("the firewall deletion was not retried")
+	}
+	assertCallOrder(t, client, "DeleteInstance", "UnassignReservedIP", "DeleteReservedIP", "DeleteSSHKey", "DeleteFirewall")
+}
+
+func TestReaperFailure(t *testing.T) {
+	civo.UseFakeClock(t)
+	client := civofake.NewClient()
+	old := time.Now().Add(-48 * time.Hour)
+
+	client.AddSSHKey(civogo.SSHKey{Name: nameAt(old)})
+	client.AddSSHKey(civogo.SSHKey{Name: nameAt(old.Add(time.Minute))})
+	client.FailNext("DeleteSSHKey", civogo.HTTPError{Code: 403, Status: "403 Forbidden"})
+
+	var out bytes.Buffer
+	reaper := &civo.Reaper{Client: client, OlderThan: 24 * time.Hour, Out: &out}
+	if err := reaper.Reap(); err == nil {
+		t.Fatal("expected an error")
+	}
+	// It carries on after a failure
+	if keys := client.SSHKeys(); len(keys) != 1 {
+		t.Fatalf("expected one ssh key left, got %+v", keys)
+	}
+}
@@ -0,0 +1,115 @@
+package civo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/civo/civogo"
+)
+
+// ReservedIP is a public IP address kept in the account independently
+// of any instance.
+type ReservedIP struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	IP         string             `json:"ip"`
+	AssignedTo ReservedIPAssignee `json:"assigned_to"`
+}
+
+// ReservedIPAssignee is the resource a reserved IP is attached to.
+type ReservedIPAssignee struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type reservedIPPage struct {
+	Page  int          `json:"page"`
+	Pages int          `json:"pages"`
+	Items []ReservedIP `json:"items"`
+}
+
+type reservedIPAction struct {
+	Action       string `json:"action"`
+	AssignToID   string `json:"assign_to_id,omitempty"`
+	AssignToType string `json:"assign_to_type,omitempty"`
+	Region       string `json:"region"`
+}
+
+// ListReservedIPs returns the reserved IPs in region.
+func (c *apiClient) ListReservedIPs(region string) ([]ReservedIP, error) {
+	var ips []ReservedIP
+	for page := 1; ; page++ {
+		resp, err := c.SendGetRequest(fmt.Sprintf("/v2/ips?region=%s&page=%d&per_page=100", url.QueryEscape(region), page))
+		if err != nil {
+			return nil, err
+		}
+
+		result := reservedIPPage{}
+		if err := json.Unmarshal(resp, &result); err != nil {
+			return nil, fmt.Errorf("%w: %s", civogo.ResponseDecodeFailedError, err)
+		}
+		ips = append(ips, result.Items...)
+		if page >= result.Pages {
+			return ips, nil
+		}
+	}
+}
+
+// NewReservedIP allocates a reserved IP in region.
+func (c *apiClient) NewReservedIP(name, region string) (*ReservedIP, error) {
+	resp, err := c.SendPostRequest("/v2/ips", map[string]string{
+		"name":   name,
+		"region": region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ip := &ReservedIP{}
+	if err := json.Unmarshal(resp, ip); err != nil {
+		return nil, fmt.Errorf("%w: %s", civogo.ResponseDecodeFailedError, err)
+	}
+	return ip, nil
+}
+
+// GetReservedIP returns the reserved IP with the given ID.
+func (c *apiClient) GetReservedIP(id, region string) (*ReservedIP, error) {
+	resp, err := c.SendGetRequest(fmt.Sprintf("/v2/ips/%s?region=%s", url.PathEscape(id), url.QueryEscape(region)))
+	if err != nil {
+		return nil, err
+	}
+
+	ip := &ReservedIP{}
+	if err := json.Unmarshal(resp, ip); err != nil {
+		return nil, fmt.Errorf("%w: %s", civogo.ResponseDecodeFailedError, err)
+	}
+	return ip, nil
+}
+
+// DeleteReservedIP releases a reserved IP.
+func (c *apiClient) DeleteReservedIP(id, region string) error {
+	_, err := c.SendDeleteRequest(fmt.Sprintf("/v2/ips/%s?region=%s", url.PathEscape(id), url.QueryEscape(region)))
+	return err
+}
+
+// AssignReservedIP attaches a reserved IP to an instance.
+func (c *apiClient) AssignReservedIP(id, instanceID, region string) error {
+	_, err := c.SendPostRequest(fmt.Sprintf("/v2/ips/%s/actions", url.PathEscape(id)), reservedIPAction{
+		Action:       "assign",
+		AssignToID:   instanceID,
+		AssignToType: "instance",
+		Region:       region,
+	})
+	return err
+}
+
+// UnassignReservedIP detaches a reserved IP from whatever it is attached to.
+func (c *apiClient) UnassignReservedIP(id, region string) error {
+	_, err := c.SendPostRequest(fmt.Sprintf("/v2/ips/%s/actions", url.PathEscape(id)), reservedIPAction{
+		Action: "unassign",
+		Region: region,
+	})
+	return err
+}
@@ -0,0 +1,100 @@
+package civo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/civo/civogo"
+)
+
+// sourceImage is the template or snapshot the build instance is
+// launched from. Exactly one of TemplateID and SnapshotID is set.
+type sourceImage struct {
+	TemplateID string
+	SnapshotID string
+	Name       string
+}
+
+// ID returns the ID of the template or snapshot.
+func (s *sourceImage) ID() string {
+	if s.SnapshotID != "" {
+		return s.SnapshotID
+	}
+	return s.TemplateID
+}
+
+// findSourceImage resolves the configured source to a concrete
+// template or snapshot available in the build region.
+func findSourceImage(client CivoAPI, c *Config) (*sourceImage, error) {
+	switch {
+	case !c.SourceImageFilter.Empty():
+		return findFilteredSourceImage(client, &c.SourceImageFilter, c.Region)
+
+	case c.SourceSnapshot != "":
+		return findSourceSnapshot(client, c.SourceSnapshot, c.Region)
+
+	case c.SourceImageID != "":
+		// Custom disk images are account-owned templates
+		templates, err := client.ListTemplates()
+		if err != nil {
+			return nil, fmt.Errorf("Error listing templates: %s", err)
+		}
+		for _, t := range templates {
+			if t.ID == c.SourceImageID {
+				return &sourceImage{TemplateID: t.ID, Name: t.Name}, nil
+			}
+		}
+		return nil, fmt.Errorf("Source image %q not found in region %s", c.SourceImageID, c.Region)
+
+	default:
+		template, err := client.FindTemplate(c.Template)
+		if err != nil {
+			return nil, fmt.Errorf("Error finding template %q: %s", c.Template, err)
+		}
+		return &sourceImage{TemplateID: template.ID, Name: template.Code}, nil
+	}
+}
+
+// findSourceSnapshot returns the complete snapshot in region whose ID or
+// name is exactly search.
+func findSourceSnapshot(client CivoAPI, search string, region string) (*sourceImage, error) {
+	snapshots, err := client.ListSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing snapshots: %s", err)
+	}
+
+	var found []civogo.Snapshot
+	var elsewhere []string
+	for _, s := range snapshots {
+		if s.ID != search && s.Name != search {
+			continue
+		}
+		if s.Region != region {
+			elsewhere = append(elsewhere, s.Region)
+			continue
+		}
+		found = append(found, s)
+	}
+
+	switch {
+	case len(found) == 0 && len(elsewhere) > 0:
+		return nil, fmt.Errorf("Source snapshot %q not found in region %s, only in %s",
+			search, region, strings.Join(elsewhere, ", "))
+	case len(found) == 0:
+		return nil, fmt.Errorf("Source snapshot %q not found in region %s", search, region)
+	case len(found) > 1:
+		ids := make([]string, 0, len(found))
+		for _, s := range found {
+			ids = append(ids, s.ID)
+		}
+		return nil, fmt.Errorf("Several snapshots are named %q in region %s (IDs: %s), use the ID instead",
+			search, region, strings.Join(ids, ", "))
+	}
+
+	snapshot := found[0]
+	if snapshot.State != "complete" {
+		return nil, fmt.Errorf("Source snapshot %q is not complete (state: %s)",
+			snapshot.Name, snapshot.State)
+	}
+	return &sourceImage{SnapshotID: snapshot.ID, Name: snapshot.Name}, nil
+}
@@ -0,0 +1,136 @@
+package civo
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Owners accepted by SourceImageFilter, besides Civo account IDs.
+const (
+	ownerSelf = "self"
+	ownerCivo = "civo"
+)
+
+// SourceImageFilter selects the image to launch the build instance from
+// among the templates and snapshots visible to the account.
+type SourceImageFilter struct {
+	// A regular expression the code or name of the template, or the name
+	// of the snapshot, must match.
+	NameRegex string `mapstructure:"name_regex" required:"false"`
+	// Who the image must belong to: `self` for your snapshots and custom
+	// templates, `civo` for public templates, or a Civo account ID.
+	// Defaults to `["self", "civo"]`.
+	Owners []string `mapstructure:"owners" required:"false"`
+	// When several images match, pick the snapshot that completed last
+	// instead of failing. Templates carry no creation date, so they can
+	// not be ordered this way.
+	MostRecent bool `mapstructure:"most_recent" required:"false"`
+
+	nameRegex *regexp.Regexp
+}
+
+// Empty reports whether the filter has not been configured.
+func (f *SourceImageFilter) Empty() bool {
+	return f.NameRegex == "" && len(f.Owners) == 0 && !f.MostRecent
+}
+
+// Prepare validates the filter and sets its defaults.
+func (f *SourceImageFilter) Prepare() []error {
+	var errs []error
+
+	if f.NameRegex == "" {
+		errs = append(errs, fmt.Errorf("source_image_filter: name_regex is required"))
+	} else {
+		re, err := regexp.Compile(f.NameRegex)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source_image_filter: invalid name_regex: %s", err))
+		}
+		f.nameRegex = re
+	}
+
+	if len(f.Owners) == 0 {
+		f.Owners = []string{ownerSelf, ownerCivo}
+	}
+
+	return errs
+}
+
+func (f *SourceImageFilter) hasOwner(owner string) bool {
+	for _, o := range f.Owners {
+		if o == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// findFilteredSourceImage returns the single template or snapshot in
+// region matching the filter.
+func findFilteredSourceImage(client CivoAPI, f *SourceImageFilter, region string) (*sourceImage, error) {
+	var candidates []*sourceImage
+	var completedAt = make(map[string]int64)
+
+	templates, err := client.ListTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing templates: %s", err)
+	}
+	for _, t := range templates {
+		owned := (t.AccountID == "" && f.hasOwner(ownerCivo)) ||
+			(t.AccountID != "" && (f.hasOwner(ownerSelf) || f.hasOwner(t.AccountID)))
+		if owned && (f.nameRegex.MatchString(t.Code) || f.nameRegex.MatchString(t.Name)) {
+			candidates = append(candidates, &sourceImage{TemplateID: t.ID, Name: t.Code})
+		}
+	}
+
+	// Snapshots are only ever listed for the calling account
+	if f.hasOwner(ownerSelf) {
+		snapshots, err := client.ListSnapshots()
+		if err != nil {
+			return nil, fmt.Errorf("Error listing snapshots: %s", err)
+		}
+		for _, s := range snapshots {
+			if s.Region == region && s.State == "complete" && f.nameRegex.MatchString(s.Name) {
+				candidates = append(candidates, &sourceImage{SnapshotID: s.ID, Name: s.Name})
+				completedAt[s.ID] = s.CompletedAt.UnixNano()
+			}
+		}
+	}
+
+	log.Printf("source_image_filter matched %d image(s)", len(candidates))
+	switch {
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("No template or snapshot in %s matches source_image_filter", region)
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case !f.MostRecent:
+		return nil, fmt.Errorf("source_image_filter matches several images (%s), "+
+			"narrow it down or set most_recent", imageNames(candidates))
+	}
+
+	var snapshots []*sourceImage
+	for _, c := range candidates {
+		if c.SnapshotID != "" {
+			snapshots = append(snapshots, c)
+		}
+	}
+	if len(snapshots) != len(candidates) {
+		return nil, fmt.Errorf("source_image_filter matches templates, which can not be ordered "+
+			"by date (%s), narrow it down", imageNames(candidates))
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return completedAt[snapshots[i].SnapshotID] > completedAt[snapshots[j].SnapshotID]
+	})
+	return snapshots[0], nil
+}
+
+func imageNames(images []*sourceImage) string {
+	names := make([]string, 0, len(images))
+	for _, i := range images {
+		names = append(names, i.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,132 @@
+package civo_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+)
+
+func TestFindFilteredSourceImage(t *testing.T) {
+	now := time.Now()
+	client := civofake.NewClient()
+	client.SnapshotPolls = 1000
+	focal := client.AddTemplate(civogo.Template{Code: "ubuntu-focal", Name: "Ubuntu 20.04"})
+	own := client.AddTemplate(civogo.Template{Code: "app-template", Name: "App", AccountID: "acct-1"})
+	shared := client.AddTemplate(civogo.Template{Code: "shared-image", Name: "Shared", AccountID: "acct-2"})
+	client.AddSnapshot(civogo.Snapshot{Name: "app-1", Region: "LON1", State: "complete", CompletedAt: now.Add(-2 * time.Hour)})
+	newest := client.AddSnapshot(civogo.Snapshot{Name: "app-2", Region: "LON1", State: "complete", CompletedAt: now.Add(-time.Hour)})
+	client.AddSnapshot(civogo.Snapshot{Name: "app-3", Region: "LON1", State: "complete", CompletedAt: now.Add(-3 * time.Hour)})
+	client.AddSnapshot(civogo.Snapshot{Name: "app-nyc", Region: "NYC1", State: "complete", CompletedAt: now})
+	client.AddSnapshot(civogo.Snapshot{Name: "app-pending", Region: "LON1", State: "pending"})
+	snap := client.AddSnapshot(civogo.Snapshot{Name: "ubuntu-snap", Region: "LON1", State: "complete", CompletedAt: now})
+
+	cases := []struct {
+		name         string
+		filter       civo.SourceImageFilter
+		wantTemplate string
+		wantSnapshot string
+		err          string
+	}{
+		{
+			name:         "civo template",
+			filter:       civo.SourceImageFilter{NameRegex: "^ubuntu-focal$", Owners: []string{"civo"}},
+			wantTemplate: focal.ID,
+		},
+		{
+			name:         "civo template by name",
+			filter:       civo.SourceImageFilter{NameRegex: "^Ubuntu 20", Owners: []string{"civo"}},
+			wantTemplate: focal.ID,
+		},
+		{
+			name:   "civo template not owned by self",
+			filter: civo.SourceImageFilter{NameRegex: "^ubuntu-focal$", Owners: []string{"self"}},
+			err:    "No template or snapshot in LON1 matches source_image_filter",
+		},
+		{
+			name:         "own template with default owners",
+			filter:       civo.SourceImageFilter{NameRegex: "^app-template$"},
+			wantTemplate: own.ID,
+		},
+		{
+			name:   "own template not owned by civo",
+			filter: civo.SourceImageFilter{NameRegex: "^app-template$", Owners: []string{"civo"}},
+			err:    "No template or snapshot in LON1 matches source_image_filter",
+		},
+		{
+			name:         "template of an account",
+			filter:       civo.SourceImageFilter{NameRegex: "^shared-", Owners: []string{"acct-2"}},
+			wantTemplate: shared.ID,
+		},
+		{
+			name:   "template of another account",
+			filter: civo.SourceImageFilter{NameRegex: "^app-template$", Owners: []string{"acct-2"}},
+			err:    "No template or snapshot in LON1 matches source_image_filter",
+		},
+		{
+			name:         "snapshot",
+			filter:       civo.SourceImageFilter{NameRegex: "^ubuntu-snap$"},
+			wantSnapshot: snap.ID,
+		},
+		{
+			name:   "snapshots belong to self",
+			filter: civo.SourceImageFilter{NameRegex: "^ubuntu-snap$", Owners: []string{"civo", "acct-2"}},
+			err:    "No template or snapshot in LON1 matches source_image_filter",
+		},
+		{
+			name:   "snapshot in another region",
+			filter: civo.SourceImageFilter{NameRegex: "^app-nyc$"},
+			err:    "No template or snapshot in LON1 matches source_image_filter",
+		},
+		{
+			name:   "pending snapshot",
+			filter: civo.SourceImageFilter{NameRegex: "^app-pending$"},
+			err:    "No template or snapshot in LON1 matches source_image_filter",
+		},
+		{
+			name:   "several snapshots",
+			filter: civo.SourceImageFilter{NameRegex: `^app-\d$`},
+			err:    "matches several images (",
+		},
+		{
+			name:         "most recent snapshot",
+			filter:       civo.SourceImageFilter{NameRegex: `^app-\d$`, MostRecent: true},
+			wantSnapshot: newest.ID,
+		},
+		{
+			name:   "templates can not be ordered",
+			filter: civo.SourceImageFilter{NameRegex: "^app-", MostRecent: true},
+			err:    "matches templates, which can not be ordered by date",
+		},
+		{
+			name:   "template and snapshot",
+			filter: civo.SourceImageFilter{NameRegex: "^ubuntu-"},
+			err:    "matches several images (ubuntu-focal, ubuntu-snap)",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &civo.Config{Region: "LON1", SourceImageFilter: tc.filter}
+			if errs := c.SourceImageFilter.Prepare(); len(errs) > 0 {
+				t.Fatalf("Prepare: %v", errs)
+			}
+
+			source, err := civo.FindSourceImage(client, c)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if source.TemplateID != tc.wantTemplate || source.SnapshotID != tc.wantSnapshot {
+				t.Fatalf("found %+v, want template %q snapshot %q", source, tc.wantTemplate, tc.wantSnapshot)
+			}
+		})
+	}
+}
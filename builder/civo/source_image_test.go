@@ -0,0 +1,84 @@
+package civo_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+)
+
+func TestFindSourceSnapshot(t *testing.T) {
+	client := civofake.NewClient()
+	client.SnapshotPolls = 1000
+	base := client.AddSnapshot(civogo.Snapshot{Name: "nginx-base", Region: "LON1", State: "complete"})
+	v2 := client.AddSnapshot(civogo.Snapshot{Name: "nginx-base-v2", Region: "LON1", State: "complete"})
+	client.AddSnapshot(civogo.Snapshot{Name: "nginx-base", Region: "NYC1", State: "complete"})
+	client.AddSnapshot(civogo.Snapshot{Name: "v2-only", Region: "LON1", State: "complete"})
+	client.AddSnapshot(civogo.Snapshot{Name: "elsewhere", Region: "NYC1", State: "complete"})
+	client.AddSnapshot(civogo.Snapshot{Name: "pending", Region: "LON1", State: "pending"})
+	dup1 := client.AddSnapshot(civogo.Snapshot{Name: "dup", Region: "LON1", State: "complete"})
+	client.AddSnapshot(civogo.Snapshot{Name: "dup", Region: "LON1", State: "complete"})
+
+	cases := []struct {
+		search string
+		want   string
+		err    string
+	}{
+		{search: "nginx-base", want: base.ID},
+		{search: "nginx-base-v2", want: v2.ID},
+		{search: v2.ID, want: v2.ID},
+		{search: dup1.ID, want: dup1.ID},
+		{search: "nginx", err: `"nginx" not found in region LON1`},
+		{search: "v2", err: `"v2" not found in region LON1`},
+		{search: "elsewhere", err: "not found in region LON1, only in NYC1"},
+		{search: "pending", err: "is not complete"},
+		{search: "dup", err: "Several snapshots are named"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.search, func(t *testing.T) {
+			c := &civo.Config{SourceSnapshot: tc.search, Region: "LON1"}
+			source, err := civo.FindSourceImage(client, c)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if source.SnapshotID != tc.want {
+				t.Fatalf("found %s, want %s", source.SnapshotID, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindSourceImageID(t *testing.T) {
+	client := civofake.NewClient()
+	image := client.AddTemplate(civogo.Template{ID: "image-1234", Name: "custom"})
+
+	c := &civo.Config{SourceImageID: image.ID, Region: "LON1"}
+	source, err := civo.FindSourceImage(client, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if source.TemplateID != image.ID || source.Name != "custom" {
+		t.Fatalf("unexpected source %+v", source)
+	}
+
+	c.SourceImageID = "image-12"
+	if _, err := civo.FindSourceImage(client, c); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("partial ID matched: %v", err)
+	}
+
+	injected := errors.New("injected failure")
+	client.FailNext("ListTemplates", injected)
+	c.SourceImageID = image.ID
+	if _, err := civo.FindSourceImage(client, c); err == nil || !strings.Contains(err.Error(), injected.Error()) {
+		t.Fatalf("API error not reported: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package civo
+
+import (
+	"crypto/ed25519"
+	"encoding/pem"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Temporary key pair types and their default sizes. Keys are generated
+// with sshkey.GeneratePair, which also supports dsa, but OpenSSH no longer
+// accepts dsa keys by default.
+const (
+	keyPairTypeED25519 = "ed25519"
+	keyPairTypeECDSA   = "ecdsa"
+	keyPairTypeRSA     = "rsa"
+
+	defaultRSABits   = 2048
+	defaultECDSABits = 521
+)
+
+// prepareTemporaryKeyPair validates the temporary key pair type and
+// size, and fills in the defaults.
+func prepareTemporaryKeyPair(keyType *string, bits *int) []error {
+	var errs []error
+
+	if *keyType == "" {
+		*keyType = keyPairTypeRSA
+	}
+
+	switch *keyType {
+	case keyPairTypeED25519:
+		if *bits != 0 {
+			errs = append(errs, fmt.Errorf(
+				"temporary_key_pair_bits can not be set for %s keys, they have a fixed size", *keyType))
+		}
+	case keyPairTypeECDSA:
+		if *bits == 0 {
+			*bits = defaultECDSABits
+		}
+		if *bits != 256 && *bits != 384 && *bits != 521 {
+			errs = append(errs, fmt.Errorf(
+				"temporary_key_pair_bits must be 256, 384 or 521 for ecdsa keys, not %d", *bits))
+		}
+	case keyPairTypeRSA:
+		if *bits == 0 {
+			*bits = defaultRSABits
+		}
+		if *bits < 2048 {
+			errs = append(errs, fmt.Errorf(
+				"temporary_key_pair_bits must be at least 2048 for rsa keys, not %d", *bits))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(
+			"temporary_key_pair_type must be one of ed25519, ecdsa or rsa, not %q", *keyType))
+	}
+
+	return errs
+}
+
+// openSSHPrivateKey encodes a private key generated by sshkey.GeneratePair,
+// which writes rsa and ecdsa keys as PKCS#8, in the OpenSSH format
+// ssh-keygen uses for every type.
+func openSSHPrivateKey(private []byte, comment string) ([]byte, error) {
+	key, err := ssh.ParseRawPrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := key.(*ed25519.PrivateKey); ok {
+		key = *k
+	}
+
+	block, err := ssh.MarshalPrivateKey(key, comment)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(block), nil
+}
@@ -0,0 +1,111 @@
+package civo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type stepAttachReservedIP struct {
+	// The reserved IP attached to the instance
+	reservedIPID string
+	// The reserved IP allocated for the build, released in cleanup
+	createdID string
+}
+
+func (s *stepAttachReservedIP) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+	instanceID := state.Get("instance_id").(string)
+
+	var ip *ReservedIP
+	switch {
+	case c.ReservedIP == "create":
+		ui.Say("Allocating temporary reserved IP...")
+		created, err := client.NewReservedIP(resourceName(), c.Region)
+		if err != nil {
+			err := fmt.Errorf("Error allocating reserved IP: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		// We use this in cleanup
+		s.createdID = created.ID
+		journalAdd(state, journalEntry{Kind: kindReservedIP, ID: created.ID, Name: created.Name, Region: c.Region})
+		ip = created
+	case c.reservedIPID() != "":
+		id := c.reservedIPID()
+		existing, err := client.GetReservedIP(id, c.Region)
+		if err != nil {
+			err := fmt.Errorf("Error retrieving reserved IP %s: %s", id, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if existing.AssignedTo.ID != "" {
+			err := fmt.Errorf("Reserved IP %s (%s) is already attached to %s %s",
+				existing.Name, existing.IP, existing.AssignedTo.Type, existing.AssignedTo.Name)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		ip = existing
+	default:
+		return multistep.ActionContinue
+	}
+
+	// Reserved IPs can only be attached to running instances
+	ui.Say("Waiting for instance to become active...")
+	err := waitForInstanceState(ctx, ui, "ACTIVE", instanceID, client, c.StateTimeout)
+	if err != nil {
+		err := fmt.Errorf("Error waiting for instance to become active: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Attaching reserved IP %s...", ip.IP))
+	if err := client.AssignReservedIP(ip.ID, instanceID, c.Region); err != nil {
+		err := fmt.Errorf("Error attaching reserved IP: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// We use this in cleanup
+	s.reservedIPID = ip.ID
+
+	state.Put("reserved_ip", ip)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepAttachReservedIP) Cleanup(state multistep.StateBag) {
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	// Detach the reserved IP before the instance is destroyed, so that
+	// it stays in the account
+	if s.reservedIPID != "" {
+		ui.Say("Detaching reserved IP...")
+		if err := client.UnassignReservedIP(s.reservedIPID, c.Region); err != nil {
+			ui.Error(fmt.Sprintf(
+				"Error detaching reserved IP. Please detach it manually: %s", err))
+		}
+	}
+
+	if s.createdID != "" {
+		ui.Say("Releasing temporary reserved IP...")
+		if err := client.DeleteReservedIP(s.createdID, c.Region); err != nil && !isNotFound(err) {
+			ui.Error(fmt.Sprintf(
+				"Error releasing temporary reserved IP. Please release it manually: %s", err))
+			return
+		}
+		journalRemove(state, kindReservedIP, s.createdID)
+	}
+}
@@ -0,0 +1,101 @@
+package civo_test
+
+import (
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepAttachReservedIPCreate(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{"reserved_ip": "create"})
+	instance := e.addInstance(civogo.Instance{Status: civofake.StatusBuilding})
+	step := &civo.StepAttachReservedIP{}
+
+	e.run(t, step, multistep.ActionContinue)
+
+	ips := e.client.ReservedIPs()
+	if len(ips) != 1 || ips[0].AssignedTo.ID != instance.ID {
+		t.Fatalf("reserved IP not attached to %s: %+v", instance.ID, ips)
+	}
+	if got := e.state.Get("reserved_ip").(*civo.ReservedIP); got.ID != ips[0].ID {
+		t.Fatalf("reserved_ip is %+v", got)
+	}
+	// Reserved IPs can only be attached to active instances
+	assertCallOrder(t, e.client, "NewReservedIP", "GetInstance", "AssignReservedIP")
+
+	step.Cleanup(e.state)
+	if ips := e.client.ReservedIPs(); len(ips) != 0 {
+		t.Fatalf("reserved IPs left: %+v", ips)
+	}
+}
+
+func TestStepAttachReservedIPExisting(t *testing.T) {
+	e := newStepEnv(t, nil)
+	ip := e.client.AddReservedIP(civo.ReservedIP{Name: "mine"})
+	e.config.ReservedIP = ip.ID
+	instance := e.addInstance(civogo.Instance{})
+	step := &civo.StepAttachReservedIP{}
+
+	e.run(t, step, multistep.ActionContinue)
+
+	if ips := e.client.ReservedIPs(); ips[0].AssignedTo.ID != instance.ID {
+		t.Fatalf("reserved IP not attached to %s: %+v", instance.ID, ips)
+	}
+
+	step.Cleanup(e.state)
+	ips := e.client.ReservedIPs()
+	if len(ips) != 1 {
+		t.Fatal("the existing reserved IP was released")
+	}
+	if ips[0].AssignedTo.ID != "" {
+		t.Fatalf("reserved IP still attached to %s", ips[0].AssignedTo.ID)
+	}
+}
+
+func TestStepAttachReservedIPAlreadyAssigned(t *testing.T) {
+	e := newStepEnv(t, nil)
+	other := e.client.AddInstance(civogo.Instance{Hostname: "other"})
+	ip := e.client.AddReservedIP(civo.ReservedIP{
+		AssignedTo: civo.ReservedIPAssignee{ID: other.ID, Type: "instance", Name: other.Hostname},
+	})
+	e.config.ReservedIP = ip.ID
+	e.addInstance(civogo.Instance{})
+	step := &civo.StepAttachReservedIP{}
+
+	e.run(t, step, multistep.ActionHalt)
+	step.Cleanup(e.state)
+
+	if ips := e.client.ReservedIPs(); ips[0].AssignedTo.ID != other.ID {
+		t.Fatal("reserved IP taken away from the instance it was attached to")
+	}
+}
+
+func TestStepAttachReservedIPFailure(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{"reserved_ip": "create"})
+	e.addInstance(civogo.Instance{})
+	e.client.FailNext("AssignReservedIP", civogo.HTTPError{Code: 422, Status: "422 Unprocessable Entity"})
+	step := &civo.StepAttachReservedIP{}
+
+	e.run(t, step, multistep.ActionHalt)
+	step.Cleanup(e.state)
+
+	if ips := e.client.ReservedIPs(); len(ips) != 0 {
+		t.Fatalf("reserved IPs left: %+v", ips)
+	}
+}
+
+func TestStepAttachReservedIPNone(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.addInstance(civogo.Instance{})
+	step := &civo.StepAttachReservedIP{}
+
+	e.run(t, step, multistep.ActionContinue)
+	step.Cleanup(e.state)
+
+	if len(e.client.Calls()) != 0 {
+		t.Fatalf("unexpected calls %v", e.client.Calls())
+	}
+}
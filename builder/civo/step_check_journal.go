@@ -0,0 +1,66 @@
+package civo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepCheckJournal offers to delete the resources a previous run of the
+// same build created but never deleted, e.g. because it was killed.
+type stepCheckJournal struct{}
+
+func (s *stepCheckJournal) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	j, ok := state.Get("journal").(*journal)
+	if !ok {
+		return multistep.ActionContinue
+	}
+	entries := j.entries()
+	if len(entries) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui.Say(fmt.Sprintf("A previous run of this build left %d resources behind:", len(entries)))
+	for _, e := range entries {
+		ui.Message(fmt.Sprintf("%s, created %s", e, e.CreatedAt.Local().Format("2006-01-02 15:04:05")))
+	}
+
+	if !c.CleanupPreviousRun {
+		answer, err := ui.Ask("Delete them now? [y/N]")
+		if err != nil {
+			ui.Message(fmt.Sprintf(
+				"Leaving them, set cleanup_previous_run to delete them without asking. They are recorded in %s", j.path))
+			return multistep.ActionContinue
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			ui.Message(fmt.Sprintf("Leaving them. They are recorded in %s", j.path))
+			return multistep.ActionContinue
+		}
+	}
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return multistep.ActionHalt
+		}
+
+		ui.Say(fmt.Sprintf("Deleting %s...", e))
+		if err := deleteLeftover(ctx, client, e.Kind, e.ID, e.Region, c.StateTimeout); err != nil {
+			ui.Error(fmt.Sprintf("Error deleting %s, it stays recorded in %s: %s", e, j.path, err))
+			continue
+		}
+		journalRemove(state, e.Kind, e.ID)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckJournal) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
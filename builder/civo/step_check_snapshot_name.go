@@ -0,0 +1,68 @@
+package civo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepCheckSnapshotName fails the build before anything is created when
+// a snapshot with the configured name already exists in the region,
+// unless it is to be replaced. Civo then overwrites one of them in place
+// when the new snapshot is taken, and stepSnapshot deletes any other
+// once the new snapshot is complete.
+type stepCheckSnapshotName struct {
+	Force bool
+}
+
+func (s *stepCheckSnapshotName) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	if c.SkipCreateImage {
+		return multistep.ActionContinue
+	}
+
+	snapshots, err := client.ListSnapshots()
+	if err != nil {
+		err := fmt.Errorf("Error listing snapshots: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	var existing []string
+	for _, snapshot := range snapshots {
+		// Snapshots are listed for the whole account
+		if snapshot.Name == c.SnapshotName && snapshot.Region == c.Region {
+			existing = append(existing, snapshot.ID)
+		}
+	}
+	if len(existing) == 0 {
+		return multistep.ActionContinue
+	}
+
+	if !s.Force {
+		err := fmt.Errorf(
+			"A snapshot named %s already exists in region %s (ID: %s), set force_delete_snapshot or use -force to replace it",
+			c.SnapshotName, c.Region, existing[0])
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Creating a snapshot is a create or update by name, the old one can
+	// not be kept until the new one is complete
+	ui.Say(fmt.Sprintf(
+		"A snapshot named %s already exists in region %s. Civo overwrites it in place when the new snapshot "+
+			"is taken, so if the build fails from then on, it is lost", c.SnapshotName, c.Region))
+	state.Put("replaced_snapshot_ids", existing)
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckSnapshotName) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
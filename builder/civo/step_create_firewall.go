@@ -0,0 +1,169 @@
+package civo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/civo/civogo"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type stepCreateFirewall struct {
+	firewallID string
+}
+
+func (s *stepCreateFirewall) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	if c.FirewallID != "" {
+		if err := checkFirewall(client, c.FirewallID, c.Region); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("firewall_id", c.FirewallID)
+		return multistep.ActionContinue
+	}
+
+	if !c.TemporaryFirewall {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Creating temporary firewall...")
+
+	cidrs := c.TemporaryFirewallSourceCidrs
+	if len(cidrs) == 0 {
+		ip, err := detectPublicIP(ctx)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		cidrs = []string{fmt.Sprintf("%s/32", ip)}
+	}
+
+	name := resourceName()
+	firewall, err := client.NewFirewall(name)
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary firewall: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// We use this in cleanup
+	s.firewallID = firewall.ID
+	journalAdd(state, journalEntry{Kind: kindFirewall, ID: firewall.ID, Name: name, Region: c.Region})
+
+	// New firewalls may come with default rules, only keep ours
+	rules, err := client.ListFirewallRules(firewall.ID)
+	if err != nil {
+		err := fmt.Errorf("Error listing temporary firewall rules: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	for _, rule := range rules {
+		if _, err := client.DeleteFirewallRule(firewall.ID, rule.ID); err != nil {
+			err := fmt.Errorf("Error removing default firewall rule: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	port := strconv.Itoa(c.Comm.Port())
+	_, err = client.NewFirewallRule(&civogo.FirewallRuleConfig{
+		FirewallID: firewall.ID,
+		Protocol:   "tcp",
+		StartPort:  port,
+		EndPort:    port,
+		Cidr:       cidrs,
+		Direction:  "ingress",
+		Label:      "packer " + c.Comm.Type,
+	})
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary firewall rule: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Printf("temporary firewall name: %s", name)
+	ui.Message(fmt.Sprintf("Allowing port %s from %s", port, strings.Join(cidrs, ", ")))
+	state.Put("firewall_id", firewall.ID)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateFirewall) Cleanup(state multistep.StateBag) {
+	// If no firewall ID is set, then we never created it, so just return
+	if s.firewallID == "" {
+		return
+	}
+
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	c := state.Get("config").(*Config)
+
+	// This runs after the build failed or was interrupted too, hence the
+	// fresh context.
+	ui.Say("Deleting temporary firewall...")
+	if err := deleteFirewall(context.Background(), client, s.firewallID, c.StateTimeout); err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error deleting temporary firewall. Please delete it manually: %s", err))
+		return
+	}
+	journalRemove(state, kindFirewall, s.firewallID)
+}
+
+// deleteFirewall deletes a firewall. The instances using it are destroyed
+// asynchronously and Civo refuses to delete a firewall that is still in
+// use, so it keeps trying for up to timeout.
+func deleteFirewall(ctx context.Context, client CivoAPI, id string, timeout time.Duration) error {
+	var lastErr error
+	p := &poller{
+		Description: fmt.Sprintf("firewall %s to be deleted", id),
+		Timeout:     timeout,
+	}
+	err := p.Wait(ctx, func() (bool, string, error) {
+		if _, err := client.DeleteFirewall(id); err != nil && !isNotFound(err) {
+			log.Printf("Error deleting firewall %s, retrying: %s", id, err)
+			lastErr = err
+			return false, "", nil
+		}
+		return true, "", nil
+	})
+	if err != nil && lastErr != nil {
+		return lastErr
+	}
+	return err
+}
+
+// checkFirewall verifies that the firewall exists in region.
+func checkFirewall(client CivoAPI, firewallID string, region string) error {
+	firewalls, err := client.ListFirewalls()
+	if err != nil {
+		return fmt.Errorf("Error listing firewalls: %s", err)
+	}
+
+	for _, f := range firewalls {
+		if f.ID != firewallID {
+			continue
+		}
+		if f.Region != "" && f.Region != region {
+			return fmt.Errorf("Firewall %s is in region %s, not in %s", f.Name, f.Region, region)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Firewall %s not found", firewallID)
+}
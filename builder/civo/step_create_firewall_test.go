@@ -0,0 +1,97 @@
+package civo_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civogo"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCreateFirewall(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{
+		"communicator":                    "ssh",
+		"ssh_username":                    "root",
+		"temporary_firewall":              true,
+		"temporary_firewall_source_cidrs": []string{"192.0.2.0/24"},
+	})
+	step := &civo.StepCreateFirewall{}
+
+	e.run(t, step, multistep.ActionContinue)
+
+	firewallID := e.state.Get("firewall_id").(string)
+	rules, err := e.client.ListFirewallRules(firewallID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("expected only the packer rule, got %+v", rules)
+	}
+	if rules[0].StartPort != "22" || rules[0].EndPort != "22" ||
+		!reflect.DeepEqual(rules[0].Cidr, []string{"192.0.2.0/24"}) {
+		t.Fatalf("unexpected rule %+v", rules[0])
+	}
+
+	step.Cleanup(e.state)
+	if firewalls, _ := e.client.ListFirewalls(); len(firewalls) != 0 {
+		t.Fatalf("firewalls left: %+v", firewalls)
+	}
+}
+
+func TestStepCreateFirewallCleanupRetries(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{
+		"temporary_firewall":              true,
+		"temporary_firewall_source_cidrs": []string{"192.0.2.0/24"},
+	})
+	step := &civo.StepCreateFirewall{}
+
+	e.run(t, step, multistep.ActionContinue)
+
+	// The instance is still being destroyed
+	for i := 0; i < 3; i++ {
+		e.client.FailNext("DeleteFirewall", civogo.DatabaseFirewallDeleteFailedError)
+	}
+	step.Cleanup(e.state)
+
+	if firewalls, _ := e.client.ListFirewalls(); len(firewalls) != 0 {
+		t.Fatalf("firewalls left: %+v", firewalls)
+	}
+}
+
+func TestStepCreateFirewallFailure(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{
+		"temporary_firewall":              true,
+		"temporary_firewall_source_cidrs": []string{"192.0.2.0/24"},
+	})
+	e.client.FailNext("NewFirewallRule", civogo.DatabaseFirewallRuleCreateError)
+	step := &civo.StepCreateFirewall{}
+
+	e.run(t, step, multistep.ActionHalt)
+	step.Cleanup(e.state)
+
+	if firewalls, _ := e.client.ListFirewalls(); len(firewalls) != 0 {
+		t.Fatalf("firewalls left: %+v", firewalls)
+	}
+}
+
+func TestStepCreateFirewallExisting(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{"firewall_id": "firewall-existing"})
+	step := &civo.StepCreateFirewall{}
+
+	e.run(t, step, multistep.ActionHalt)
+
+	e = newStepEnv(t, map[string]interface{}{"firewall_id": "firewall-existing"})
+	firewall, _ := e.client.NewFirewall("mine")
+	e.config.FirewallID = firewall.ID
+
+	e.run(t, step, multistep.ActionContinue)
+	step.Cleanup(e.state)
+
+	if got := e.state.Get("firewall_id"); got != firewall.ID {
+		t.Fatalf("firewall_id is %v, want %s", got, firewall.ID)
+	}
+	if firewalls, _ := e.client.ListFirewalls(); len(firewalls) != 1 {
+		t.Fatal("the existing firewall was deleted")
+	}
+}
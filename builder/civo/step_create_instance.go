@@ -6,8 +6,9 @@ import (
 	"log"
 
 	"github.com/civo/civogo"
-	"github.com/hashicorp/packer/helper/multistep"
-	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 type stepCreateInstance struct {
@@ -16,30 +17,66 @@ type stepCreateInstance struct {
 
 // Run function to run create a instance
 func (s *stepCreateInstance) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	client := state.Get("client").(*civogo.Client)
-	ui := state.Get("ui").(packer.Ui)
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
 	c := state.Get("config").(*Config)
-	sshKeyID := state.Get("ssh_key_id").(string)
+	// Only set when using the SSH communicator
+	sshKeyID, _ := state.Get("ssh_key_id").(string)
 
 	// Create the instance based on configuration
 	ui.Say("Creating instance...")
 
-	template, err := client.FindTemplate(c.Template)
+	source, err := findSourceImage(client, c)
 	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	ui.Message(fmt.Sprintf("Using source image: %s (ID: %s)", source.Name, source.ID()))
+	state.Put("source_image", source)
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("SourceImageName", source.Name)
+	generatedData.Put("SourceImageID", source.ID())
+
+	tags, err := renderTags(c.InstanceTags, c.ctx, tagTemplateData{
+		BuildName:     c.PackerBuildName,
+		SourceImage:   source.Name,
+		SourceImageID: source.ID(),
+		Region:        c.Region,
+	})
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("instance_tags", tags)
+
+	network, err := findNetwork(client, c)
+	if err != nil {
+		state.Put("error", err)
 		ui.Error(err.Error())
+		return multistep.ActionHalt
 	}
 
-	network, _ := client.GetDefaultNetwork()
+	// A reserved IP is attached once the instance is active
+	publicIP := c.PublicIP
+	if c.reservedIPID() != "" {
+		publicIP = "none"
+	}
 
 	InstanceConfig := &civogo.InstanceConfig{
 		Hostname:         c.InstanceName,
-		PublicIPRequired: c.PublicNetworking,
+		PublicIPRequired: publicIP,
 		Region:           c.Region,
 		NetworkID:        network.ID,
-		InitialUser:      c.Comm.SSHUsername,
+		InitialUser:      c.Comm.User(),
 		Size:             c.Size,
-		TemplateID:       template.ID,
+		TemplateID:       source.TemplateID,
+		SnapshotID:       source.SnapshotID,
 		SSHKeyID:         sshKeyID,
+		Script:           c.UserData,
+		Tags:             append([]string{BuilderTag}, tags...),
 	}
 
 	log.Printf("[DEBUG] Instance create paramaters: %+v", InstanceConfig)
@@ -54,9 +91,22 @@ func (s *stepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 
 	// We use this in cleanup
 	s.instanceID = instance.ID
+	journalAdd(state, journalEntry{Kind: kindInstance, ID: instance.ID, Name: instance.Hostname, Region: c.Region})
 
 	// Store the instance id for later
 	state.Put("instance_id", instance.ID)
+	generatedData.Put("InstanceID", instance.ID)
+	generatedData.Put("Region", c.Region)
+	generatedData.Put("TemplateID", source.TemplateID)
+
+	if firewallID, ok := state.GetOk("firewall_id"); ok {
+		if _, err := client.SetInstanceFirewall(instance.ID, firewallID.(string)); err != nil {
+			err := fmt.Errorf("Error applying firewall to instance: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
 
 	return multistep.ActionContinue
 }
@@ -67,14 +117,46 @@ func (s *stepCreateInstance) Cleanup(state multistep.StateBag) {
 		return
 	}
 
-	client := state.Get("client").(*civogo.Client)
-	ui := state.Get("ui").(packer.Ui)
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
 
 	// Destroy the instance we just created
 	ui.Say("Destroying instance...")
 	_, err := client.DeleteInstance(s.instanceID)
-	if err != nil {
+	if err != nil && !isNotFound(err) {
 		ui.Error(fmt.Sprintf(
 			"Error destroying instance. Please destroy it manually: %s", err))
+		return
+	}
+	journalRemove(state, kindInstance, s.instanceID)
+}
+
+// findNetwork returns the configured network, or the default one.
+func findNetwork(client CivoAPI, c *Config) (*civogo.Network, error) {
+	if c.NetworkID == "" && c.NetworkName == "" {
+		network, err := client.GetDefaultNetwork()
+		if err != nil {
+			return nil, fmt.Errorf("Error finding default network: %s", err)
+		}
+		return network, nil
+	}
+
+	networks, err := client.ListNetworks()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing networks: %s", err)
+	}
+
+	for _, n := range networks {
+		if (c.NetworkID != "" && n.ID == c.NetworkID) || (c.NetworkName != "" && n.Label == c.NetworkName) {
+			if n.Region != "" && n.Region != c.Region {
+				return nil, fmt.Errorf("Network %s is in region %s, not in %s", n.Label, n.Region, c.Region)
+			}
+			return &n, nil
+		}
+	}
+
+	if c.NetworkID != "" {
+		return nil, fmt.Errorf("Network %s not found", c.NetworkID)
 	}
+	return nil, fmt.Errorf("Network %q not found", c.NetworkName)
 }
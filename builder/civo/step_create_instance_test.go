@@ -0,0 +1,92 @@
+package civo_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCreateInstance(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{
+		"instance_tags": []string{"from-{{ .SourceImage }}"},
+		"user_data":     "#!/bin/sh",
+	})
+	firewall, _ := e.client.NewFirewall("mine")
+	e.state.Put("firewall_id", firewall.ID)
+	step := &civo.StepCreateInstance{}
+
+	e.run(t, step, multistep.ActionContinue)
+
+	instances := e.client.Instances()
+	if len(instances) != 1 {
+		t.Fatalf("expected a single instance, got %+v", instances)
+	}
+	instance := instances[0]
+	if got := e.state.Get("instance_id"); got != instance.ID {
+		t.Fatalf("instance_id is %v, want %s", got, instance.ID)
+	}
+	if instance.Status != civofake.StatusBuilding {
+		t.Fatalf("instance is %s, waiting for it is left to later steps", instance.Status)
+	}
+	if instance.Size != "g3.small" || instance.Region != "LON1" || instance.Script != "#!/bin/sh" {
+		t.Fatalf("unexpected instance %+v", instance)
+	}
+	if instance.FirewallID != firewall.ID {
+		t.Fatalf("firewall %s not applied", firewall.ID)
+	}
+	if want := []string{civo.BuilderTag, "from-debian-buster"}; !reflect.DeepEqual(instance.Tags, want) {
+		t.Fatalf("tags %v, want %v", instance.Tags, want)
+	}
+
+	step.Cleanup(e.state)
+	if instances := e.client.Instances(); len(instances) != 0 {
+		t.Fatalf("instances left: %+v", instances)
+	}
+}
+
+func TestStepCreateInstanceFailure(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.client.FailNext("CreateInstance", civogo.DatabaseInstanceCreateError)
+	step := &civo.StepCreateInstance{}
+
+	e.run(t, step, multistep.ActionHalt)
+	step.Cleanup(e.state)
+
+	if called(e.client, "DeleteInstance") {
+		t.Fatal("deleted an instance that was never created")
+	}
+}
+
+func TestStepCreateInstanceCleanupAfterLaterFailure(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.state.Put("firewall_id", "firewall-missing")
+	step := &civo.StepCreateInstance{}
+
+	e.run(t, step, multistep.ActionHalt)
+	step.Cleanup(e.state)
+
+	if instances := e.client.Instances(); len(instances) != 0 {
+		t.Fatalf("instances left: %+v", instances)
+	}
+}
+
+func TestStepCreateInstanceSourceSnapshot(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{
+		"template":        "",
+		"source_snapshot": "base",
+	})
+	snapshot := e.client.AddSnapshot(civogo.Snapshot{Name: "base", Region: "LON1", State: "complete"})
+	step := &civo.StepCreateInstance{}
+
+	e.run(t, step, multistep.ActionContinue)
+	defer step.Cleanup(e.state)
+
+	instance := e.client.Instances()[0]
+	if instance.SnapshotID != snapshot.ID || instance.TemplateID != "" {
+		t.Fatalf("instance launched from %q/%q, want snapshot %s", instance.TemplateID, instance.SnapshotID, snapshot.ID)
+	}
+}
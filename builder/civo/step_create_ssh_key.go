@@ -2,65 +2,90 @@ package civo
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"fmt"
 	"log"
 	"os"
-	"runtime"
+	"path/filepath"
+	"strings"
 
 	"github.com/civo/civogo"
-	"github.com/hashicorp/packer/common/uuid"
-	"github.com/hashicorp/packer/helper/multistep"
-	"github.com/hashicorp/packer/packer"
-	"golang.org/x/crypto/ssh"
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 type stepCreateSSHKey struct {
 	Debug        bool
 	DebugKeyPath string
+	KeepDebugKey bool
 
-	keyID string
+	keyID           string
+	debugKeyWritten bool
 }
 
 func (s *stepCreateSSHKey) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	client := state.Get("client").(*civogo.Client)
-	ui := state.Get("ui").(packer.Ui)
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
 	c := state.Get("config").(*Config)
 
+	if c.Comm.Type != "ssh" {
+		log.Printf("Not using the SSH communicator, skipping temporary ssh key")
+		return multistep.ActionContinue
+	}
+
+	// Use the key pair of the user, never created nor deleted by us
+	if c.Comm.SSHKeyPairName != "" {
+		ui.Say(fmt.Sprintf("Using existing ssh key: %s", c.Comm.SSHKeyPairName))
+		key, err := findSSHKey(client, c.Comm.SSHKeyPairName)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("ssh_key_id", key.ID)
+		return multistep.ActionContinue
+	}
+	if c.Comm.SSHPrivateKeyFile != "" || c.Comm.SSHAgentAuth {
+		log.Printf("Using the ssh key of the user, skipping temporary ssh key")
+		return multistep.ActionContinue
+	}
+
 	ui.Say("Creating temporary ssh key for instance...")
 
-	priv, err := rsa.GenerateKey(rand.Reader, 2014)
+	// The name of the public key on Civo
+	name := resourceName()
+
+	keyType := c.Comm.SSHTemporaryKeyPairType
+	algorithm, err := sshkey.AlgorithmString(keyType)
 	if err != nil {
-		err := fmt.Errorf("error generating RSA key: %s", err)
+		err := fmt.Errorf("Error creating temporary SSH key: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
-
-	// ASN.1 DER encoded form
-	privder := x509.MarshalPKCS1PrivateKey(priv)
-	privblk := pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   privder,
+	pair, err := sshkey.GeneratePair(algorithm, nil, c.Comm.SSHTemporaryKeyPairBits)
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary SSH key: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
 	}
+	privateKey, err := openSSHPrivateKey(pair.Private, name)
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary SSH key: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	log.Printf("temporary ssh key type: %s", keyType)
 
-	// Set the private key in the config for later
-	c.Comm.SSHPrivateKey = pem.EncodeToMemory(&privblk)
-
-	// Marshal the public key into SSH compatible format
-	// TODO properly handle the public key error
-	pub, _ := ssh.NewPublicKey(&priv.PublicKey)
-	pubsshformat := string(ssh.MarshalAuthorizedKey(pub))
-
-	// The name of the public key on DO
-	name := fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
+	// Set the keys in the config for later
+	c.Comm.SSHPrivateKey = privateKey
+	c.Comm.SSHPublicKey = pair.Public
 
-	// Create the key!
-	key, err := client.NewSSHKey(name, pubsshformat)
+	// Create the key! The comment names it in authorized_keys.
+	publicKey := fmt.Sprintf("%s %s", strings.TrimSpace(string(pair.Public)), name)
+	key, err := client.NewSSHKey(name, publicKey)
 	if err != nil {
 		err := fmt.Errorf("Error creating temporary SSH key: %s", err)
 		state.Put("error", err)
@@ -70,56 +95,99 @@ func (s *stepCreateSSHKey) Run(ctx context.Context, state multistep.StateBag) mu
 
 	// We use this to check cleanup
 	s.keyID = key.ID
+	journalAdd(state, journalEntry{Kind: kindSSHKey, ID: key.ID, Name: name, Region: c.Region})
 
 	log.Printf("temporary ssh key name: %s", name)
 
 	// Remember some state for the future and Save the keys in the state bag
 	state.Put("ssh_key_id", key.ID)
-	// state.Put("ssh_private_key", string(pem.EncodeToMemory(&privblk)))
-	// state.Put("ssh_public_key", string(ssh.MarshalAuthorizedKey(pub)))
 
-	// If we're in debug mode, output the private key to the working directory.
-	if s.Debug {
-		ui.Message(fmt.Sprintf("Saving key for debug purposes: %s", s.DebugKeyPath))
-		f, err := os.Create(s.DebugKeyPath)
-		if err != nil {
-			state.Put("error", fmt.Errorf("Error saving debug key: %s", err))
-			return multistep.ActionHalt
+	// If we're in debug mode, or the instance may be left running after an
+	// error, output the private key to the working directory so that the
+	// ssh command we print works.
+	if s.Debug || c.PackerOnError == "ask" || c.PackerOnError == "abort" {
+		if s.Debug {
+			ui.Message(fmt.Sprintf("Saving key for debug purposes: %s", s.DebugKeyPath))
+		} else {
+			log.Printf("Saving key in case the instance is left running: %s", s.DebugKeyPath)
 		}
-		defer f.Close()
-
-		// Write the key out
-		if _, err := f.Write(pem.EncodeToMemory(&privblk)); err != nil {
-			state.Put("error", fmt.Errorf("Error saving debug key: %s", err))
+		if err := writeKeyFile(s.DebugKeyPath, privateKey); err != nil {
+			err := fmt.Errorf("Error saving debug key: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
-
-		// Chmod it so that it is SSH ready
-		if runtime.GOOS != "windows" {
-			if err := f.Chmod(0600); err != nil {
-				state.Put("error", fmt.Errorf("Error setting permissions of debug key: %s", err))
-				return multistep.ActionHalt
-			}
-		}
+		s.debugKeyWritten = true
+		state.Put("debug_key_path", s.DebugKeyPath)
 	}
 
 	return multistep.ActionContinue
 }
 
 func (s *stepCreateSSHKey) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.debugKeyWritten && !s.KeepDebugKey {
+		if err := os.Remove(s.DebugKeyPath); err != nil && !os.IsNotExist(err) {
+			ui.Error(fmt.Sprintf(
+				"Error removing debug key. Please delete %s manually: %s", s.DebugKeyPath, err))
+		}
+	}
+
 	// If no key name is set, then we never created it, so just return
 	if s.keyID == "" {
 		return
 	}
 
-	client := state.Get("client").(*civogo.Client)
-	ui := state.Get("ui").(packer.Ui)
+	client := state.Get("client").(CivoAPI)
 
 	ui.Say("Deleting temporary ssh key...")
 	_, err := client.DeleteSSHKey(s.keyID)
-	if err != nil {
+	if err != nil && !isNotFound(err) {
 		log.Printf("Error cleaning up ssh key: %s", err)
 		ui.Error(fmt.Sprintf(
 			"Error cleaning up ssh key. Please delete the key manually: %s", err))
+		return
+	}
+	journalRemove(state, kindSSHKey, s.keyID)
+}
+
+// findSSHKey returns the SSH key with the given name or ID.
+func findSSHKey(client CivoAPI, search string) (*civogo.SSHKey, error) {
+	keys, err := client.ListSSHKeys()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing ssh keys: %s", err)
+	}
+
+	for _, k := range keys {
+		if k.ID == search || k.Name == search {
+			return &k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("SSH key %q not found", search)
+}
+
+// writeKeyFile atomically writes a private key to path, readable by the
+// current user only from the start.
+func writeKeyFile(path string, key []byte) error {
+	// CreateTemp opens the file with mode 0600
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(key)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
 	}
+	return nil
 }
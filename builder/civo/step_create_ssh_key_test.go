@@ -0,0 +1,211 @@
+package civo_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civogo"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"golang.org/x/crypto/ssh"
+)
+
+var sshConfig = map[string]interface{}{
+	"communicator": "ssh",
+	"ssh_username": "root",
+}
+
+func TestStepCreateSSHKey(t *testing.T) {
+	e := newStepEnv(t, sshConfig)
+	step := &civo.StepCreateSSHKey{}
+
+	e.run(t, step, multistep.ActionContinue)
+
+	keys := e.client.SSHKeys()
+	if len(keys) != 1 {
+		t.Fatalf("expected a single ssh key, got %+v", keys)
+	}
+	if got := e.state.Get("ssh_key_id"); got != keys[0].ID {
+		t.Fatalf("ssh_key_id is %v, want %s", got, keys[0].ID)
+	}
+	assertKeyPair(t, e.config.Comm.SSHPrivateKey, keys[0])
+
+	step.Cleanup(e.state)
+	if keys := e.client.SSHKeys(); len(keys) != 0 {
+		t.Fatalf("ssh keys left: %+v", keys)
+	}
+}
+
+func TestStepCreateSSHKeyTypes(t *testing.T) {
+	cases := []struct {
+		keyType string
+		bits    int
+	}{
+		{"rsa", 0},
+		{"rsa", 3072},
+		{"ecdsa", 0},
+		{"ecdsa", 256},
+		{"ed25519", 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%s-%d", tc.keyType, tc.bits), func(t *testing.T) {
+			raw := map[string]interface{}{
+				"temporary_key_pair_type": tc.keyType,
+			}
+			if tc.bits != 0 {
+				raw["temporary_key_pair_bits"] = tc.bits
+			}
+			for k, v := range sshConfig {
+				raw[k] = v
+			}
+			e := newStepEnv(t, raw)
+
+			e.run(t, &civo.StepCreateSSHKey{}, multistep.ActionContinue)
+
+			keys := e.client.SSHKeys()
+			if len(keys) != 1 {
+				t.Fatalf("expected a single ssh key, got %+v", keys)
+			}
+			signer := assertKeyPair(t, e.config.Comm.SSHPrivateKey, keys[0])
+			// The format ssh-keygen writes, whatever the type
+			if block, _ := pem.Decode(e.config.Comm.SSHPrivateKey); block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+				t.Fatalf("private key is not in the OpenSSH format:\n%s", e.config.Comm.SSHPrivateKey)
+			}
+			if tc.bits != 0 {
+				bits := 0
+				switch pub := signer.PublicKey().(ssh.CryptoPublicKey).CryptoPublicKey().(type) {
+				case *rsa.PublicKey:
+					bits = pub.N.BitLen()
+				case *ecdsa.PublicKey:
+					bits = pub.Curve.Params().BitSize
+				}
+				if bits != tc.bits {
+					t.Fatalf("key has %d bits, want %d", bits, tc.bits)
+				}
+			}
+		})
+	}
+}
+
+// assertKeyPair checks that the private key can be used and belongs to
+// the public key that was uploaded.
+func assertKeyPair(t *testing.T, privateKey []byte, uploaded civogo.SSHKey) ssh.Signer {
+	t.Helper()
+
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("private key not usable: %s", err)
+	}
+	if got := ssh.FingerprintSHA256(signer.PublicKey()); got != uploaded.Fingerprint {
+		t.Fatalf("private key fingerprint is %s, uploaded key is %s", got, uploaded.Fingerprint)
+	}
+	return signer
+}
+
+func TestStepCreateSSHKeyFailure(t *testing.T) {
+	e := newStepEnv(t, sshConfig)
+	e.client.FailNext("NewSSHKey", civogo.ParameterPublicKeyEmptyError)
+	step := &civo.StepCreateSSHKey{}
+
+	e.run(t, step, multistep.ActionHalt)
+	step.Cleanup(e.state)
+
+	if called(e.client, "DeleteSSHKey") {
+		t.Fatal("deleted a key that was never created")
+	}
+}
+
+func TestStepCreateSSHKeyExistingKeyPair(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{
+		"communicator":     "ssh",
+		"ssh_username":     "root",
+		"ssh_keypair_name": "mine",
+		"ssh_agent_auth":   true,
+	})
+	key := e.client.AddSSHKey(civogo.SSHKey{Name: "mine"})
+	step := &civo.StepCreateSSHKey{}
+
+	e.run(t, step, multistep.ActionContinue)
+	step.Cleanup(e.state)
+
+	if got := e.state.Get("ssh_key_id"); got != key.ID {
+		t.Fatalf("ssh_key_id is %v, want %s", got, key.ID)
+	}
+	if keys := e.client.SSHKeys(); len(keys) != 1 {
+		t.Fatalf("the existing key was deleted")
+	}
+	if called(e.client, "NewSSHKey") {
+		t.Fatal("created a key despite ssh_keypair_name")
+	}
+}
+
+func TestStepCreateSSHKeyDebug(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "civo_test.pem")
+
+	for _, keep := range []bool{false, true} {
+		e := newStepEnv(t, sshConfig)
+		step := &civo.StepCreateSSHKey{Debug: true, DebugKeyPath: path, KeepDebugKey: keep}
+
+		e.run(t, step, multistep.ActionContinue)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("debug key not written: %s", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("debug key mode %v", info.Mode().Perm())
+		}
+
+		step.Cleanup(e.state)
+		if _, err := os.Stat(path); os.IsNotExist(err) == keep {
+			t.Fatalf("with keep_debug_key %v, debug key exists after cleanup: %v", keep, !keep)
+		}
+	}
+}
+
+func TestStepCreateSSHKeyOnError(t *testing.T) {
+	for _, onError := range []string{"ask", "abort", "cleanup"} {
+		t.Run(onError, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "civo_test.pem")
+			e := newStepEnv(t, map[string]interface{}{
+				"communicator":    "ssh",
+				"ssh_username":    "root",
+				"packer_on_error": onError,
+			})
+			step := &civo.StepCreateSSHKey{DebugKeyPath: path}
+
+			e.run(t, step, multistep.ActionContinue)
+
+			// The instance is only left running with ask and abort
+			written := onError != "cleanup"
+			if _, err := os.Stat(path); os.IsNotExist(err) == written {
+				t.Fatalf("key written: %v, want %v", !written, written)
+			}
+			if _, ok := e.state.GetOk("debug_key_path"); ok != written {
+				t.Fatalf("debug_key_path set: %v, want %v", ok, written)
+			}
+
+			step.Cleanup(e.state)
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Fatal("key not deleted by cleanup")
+			}
+		})
+	}
+}
+
+func TestStepCreateSSHKeyOtherCommunicator(t *testing.T) {
+	e := newStepEnv(t, nil)
+	step := &civo.StepCreateSSHKey{}
+
+	e.run(t, step, multistep.ActionContinue)
+	step.Cleanup(e.state)
+
+	if len(e.client.Calls()) != 0 {
+		t.Fatalf("unexpected calls %v", e.client.Calls())
+	}
+}
@@ -0,0 +1,49 @@
+package civo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type stepGeneralize struct{}
+
+func (s *stepGeneralize) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	if c.GeneralizeCommand == "" {
+		return multistep.ActionContinue
+	}
+
+	comm, ok := state.Get("communicator").(packersdk.Communicator)
+	if !ok {
+		err := fmt.Errorf("generalize_command requires a communicator")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Generalizing instance...")
+	cmd := &packersdk.RemoteCmd{Command: c.GeneralizeCommand}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		err := fmt.Errorf("Error running generalize command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if status := cmd.ExitStatus(); status != 0 {
+		err := fmt.Errorf("Generalize command exited with non-zero exit status: %d", status)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepGeneralize) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
@@ -3,23 +3,24 @@ package civo
 import (
 	"context"
 	"fmt"
+	"log"
 
-	"github.com/civo/civogo"
-	"github.com/hashicorp/packer/helper/multistep"
-	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 type stepInstanceInfo struct{}
 
 func (s *stepInstanceInfo) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	client := state.Get("client").(*civogo.Client)
-	ui := state.Get("ui").(packer.Ui)
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
 	c := state.Get("config").(*Config)
 	instanceID := state.Get("instance_id").(string)
 
 	ui.Say("Waiting for instance to become active...")
 
-	err := waitForInstanceState("ACTIVE", instanceID, client, c.StateTimeout)
+	err := waitForInstanceState(ctx, ui, "ACTIVE", instanceID, client, c.StateTimeout)
 	if err != nil {
 		err := fmt.Errorf("Error waiting for instance to become active: %s", err)
 		state.Put("error", err)
@@ -36,28 +37,60 @@ func (s *stepInstanceInfo) Run(ctx context.Context, state multistep.StateBag) mu
 		return multistep.ActionHalt
 	}
 
-	// Verify we have an IPv4 address
-	invalid := instance.PublicIP == ""
-	if invalid {
-		err := fmt.Errorf("IPv4 address not found for instance")
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	if instance.PrivateIP != "" {
+		state.Put("instance_private_ip", instance.PrivateIP)
+	}
+	// The instance may not report an attached reserved IP straight away
+	if ip, ok := state.GetOk("reserved_ip"); ok {
+		instance.PublicIP = ip.(*ReservedIP).IP
 	}
-
-	// Find a public IPv4 network
-	foundNetwork := false
 	if instance.PublicIP != "" {
-		state.Put("instance_ip", instance.PublicIP)
-		foundNetwork = true
+		state.Put("instance_public_ip", instance.PublicIP)
 	}
 
-	if !foundNetwork {
-		err := fmt.Errorf("Count not find a public IPv4 address for this instance")
+	// Pick the address we connect to
+	ip := ""
+	switch c.SSHInterface {
+	case "public":
+		ip = instance.PublicIP
+	case "private":
+		ip = instance.PrivateIP
+	default:
+		ip = instance.PublicIP
+		if ip == "" {
+			ip = instance.PrivateIP
+		}
+	}
+
+	if ip == "" {
+		iface := c.SSHInterface
+		if iface == "" {
+			iface = "public or private"
+		}
+		err := fmt.Errorf("Could not find a %s IPv4 address for this instance", iface)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+	// Windows instances are given an administrator password, unless one is
+	// set through the user data and winrm_password
+	if c.Comm.Type == "winrm" && c.Comm.WinRMPassword == "" {
+		if instance.InitialPassword == "" {
+			err := fmt.Errorf("The instance has no administrator password, set winrm_password")
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		packersdk.LogSecretFilter.Set(instance.InitialPassword)
+		c.Comm.WinRMPassword = instance.InitialPassword
+	}
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("PublicIP", instance.PublicIP)
+	generatedData.Put("PrivateIP", instance.PrivateIP)
+
+	log.Printf("Connecting to the instance at %s", ip)
+	state.Put("instance_ip", ip)
 
 	return multistep.ActionContinue
 }
@@ -0,0 +1,61 @@
+package civo_test
+
+import (
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepInstanceInfo(t *testing.T) {
+	cases := []struct {
+		name      string
+		overrides map[string]interface{}
+		publicIP  string
+		want      string
+	}{
+		{"public by default", nil, "10.0.0.1", "10.0.0.1"},
+		{"private without public IP", nil, "", "192.168.1.1"},
+		{"private interface", map[string]interface{}{"ssh_interface": "private"}, "10.0.0.1", "192.168.1.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newStepEnv(t, tc.overrides)
+			instance := e.addInstance(civogo.Instance{
+				Status:    civofake.StatusBuilding,
+				PublicIP:  tc.publicIP,
+				PrivateIP: "192.168.1.1",
+			})
+
+			e.run(t, &civo.StepInstanceInfo{}, multistep.ActionContinue)
+
+			if got := e.state.Get("instance_ip"); got != tc.want {
+				t.Fatalf("instance_ip is %v, want %s", got, tc.want)
+			}
+			if got := e.client.History(instance.ID); len(got) != 2 || got[1] != civofake.StatusActive {
+				t.Fatalf("instance went through %v", got)
+			}
+		})
+	}
+}
+
+func TestStepInstanceInfoNoAddress(t *testing.T) {
+	e := newStepEnv(t, map[string]interface{}{"ssh_interface": "public"})
+	e.addInstance(civogo.Instance{PrivateIP: "192.168.1.1"})
+
+	e.run(t, &civo.StepInstanceInfo{}, multistep.ActionHalt)
+}
+
+func TestStepInstanceInfoReservedIP(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.addInstance(civogo.Instance{PrivateIP: "192.168.1.1"})
+	e.state.Put("reserved_ip", &civo.ReservedIP{IP: "10.1.0.1"})
+
+	e.run(t, &civo.StepInstanceInfo{}, multistep.ActionContinue)
+
+	if got := e.state.Get("instance_ip"); got != "10.1.0.1" {
+		t.Fatalf("instance_ip is %v, want the reserved IP", got)
+	}
+}
@@ -5,17 +5,16 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/civo/civogo"
-	"github.com/hashicorp/packer/helper/multistep"
-	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 type stepPowerOff struct{}
 
 func (s *stepPowerOff) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	client := state.Get("client").(*civogo.Client)
+	client := state.Get("client").(CivoAPI)
 	c := state.Get("config").(*Config)
-	ui := state.Get("ui").(packer.Ui)
+	ui := state.Get("ui").(packersdk.Ui)
 	instanceID := state.Get("instance_id").(string)
 
 	instance, err := client.GetInstance(instanceID)
@@ -42,7 +41,7 @@ func (s *stepPowerOff) Run(ctx context.Context, state multistep.StateBag) multis
 	}
 
 	log.Println("Waiting for poweroff event to complete...")
-	err = waitForInstanceState("SHUTOFF", instanceID, client, c.StateTimeout)
+	err = waitForInstanceState(ctx, ui, "SHUTOFF", instanceID, client, c.StateTimeout)
 	if err != nil {
 		state.Put("error", err)
 		ui.Error(err.Error())
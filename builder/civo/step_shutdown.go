@@ -6,23 +6,28 @@ import (
 	"log"
 	"time"
 
-	"github.com/civo/civogo"
-	"github.com/hashicorp/packer/helper/multistep"
-	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// How many times, and how often, the instance is asked to shut down.
+const (
+	shutdownAttempts      = 5
+	shutdownRetryInterval = 20 * time.Second
 )
 
 type stepShutdown struct{}
 
 func (s *stepShutdown) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	client := state.Get("client").(*civogo.Client)
+	client := state.Get("client").(CivoAPI)
 	c := state.Get("config").(*Config)
-	ui := state.Get("ui").(packer.Ui)
+	ui := state.Get("ui").(packersdk.Ui)
 	instanceID := state.Get("instance_id").(string)
 
 	// Gracefully power off the instance. We have to retry this a number
 	// of times because sometimes it says it completed when it actually
 	// did absolutely nothing (*ALAKAZAM!* magic!). We give up after
-	// a pretty arbitrary amount of time.
+	// shutdownAttempts tries, or when state_timeout expires.
 	ui.Say("Gracefully shutting down instance...")
 	_, err := client.StopInstance(instanceID)
 	if err != nil {
@@ -33,39 +38,34 @@ func (s *stepShutdown) Run(ctx context.Context, state multistep.StateBag) multis
 		return multistep.ActionHalt
 	}
 
-	// A channel we use as a flag to end our goroutines
-	done := make(chan struct{})
-	shutdownRetryDone := make(chan struct{})
-
-	// Make sure we wait for the shutdown retry goroutine to end
-	// before moving on.
-	defer func() {
-		close(done)
-		<-shutdownRetryDone
-	}()
-
-	// Start a goroutine that just keeps trying to shut down the
-	// instance.
-	go func() {
-		defer close(shutdownRetryDone)
+	// Keep asking the instance to shut down while waiting, a limited
+	// number of times.
+	attempts := 1
+	p := &poller{
+		Description: "instance to become SHUTOFF",
+		Timeout:     c.StateTimeout,
+		Ui:          ui,
+	}
+	lastStop := p.now()
+	err = p.Wait(ctx, func() (bool, string, error) {
+		instance, err := client.GetInstance(instanceID)
+		if err != nil {
+			return false, "", err
+		}
+		if instance.Status == "SHUTOFF" {
+			return true, instance.Status, nil
+		}
 
-		for attempts := 2; attempts > 0; attempts++ {
-			log.Printf("Shutdowninstance attempt #%d...", attempts)
-			_, err := client.StopInstance(instanceID)
-			if err != nil {
+		if attempts < shutdownAttempts && p.now().Sub(lastStop) >= shutdownRetryInterval {
+			attempts++
+			log.Printf("Shutdown instance attempt #%d...", attempts)
+			if _, err := client.StopInstance(instanceID); err != nil {
 				log.Printf("Shutdown retry error: %s", err)
 			}
-
-			select {
-			case <-done:
-				return
-			case <-time.After(20 * time.Second):
-				// Retry!
-			}
+			lastStop = p.now()
 		}
-	}()
-
-	err = waitForInstanceState("SHUTOFF", instanceID, client, c.StateTimeout)
+		return false, instance.Status, nil
+	})
 	if err != nil {
 		// If we get an error the first time, actually report it
 		err := fmt.Errorf("Error shutting down instance: %s", err)
@@ -0,0 +1,73 @@
+package civo_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepShutdown(t *testing.T) {
+	e := newStepEnv(t, nil)
+	instance := e.addInstance(civogo.Instance{})
+
+	e.run(t, &civo.StepShutdown{}, multistep.ActionContinue)
+
+	want := []string{civofake.StatusActive, civofake.StatusStopping, civofake.StatusShutoff}
+	if got := e.client.History(instance.ID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("instance went through %v, want %v", got, want)
+	}
+}
+
+func TestStepShutdownRetries(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.addInstance(civogo.Instance{})
+	// The instance ignores the requests to shut down
+	e.client.StopPolls = 1000
+
+	e.run(t, &civo.StepShutdown{}, multistep.ActionHalt)
+
+	stops := 0
+	for _, call := range e.client.Calls() {
+		if call == "StopInstance" {
+			stops++
+		}
+	}
+	// Every 20s, over the 6 minutes of state_timeout
+	if stops != 5 {
+		t.Fatalf("asked to shut down %d times, want 5", stops)
+	}
+}
+
+func TestStepShutdownFailure(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.addInstance(civogo.Instance{})
+	e.client.FailNext("StopInstance", civogo.DatabaseInstanceNotFoundError)
+
+	e.run(t, &civo.StepShutdown{}, multistep.ActionHalt)
+}
+
+func TestStepPowerOff(t *testing.T) {
+	e := newStepEnv(t, nil)
+	instance := e.addInstance(civogo.Instance{})
+
+	e.run(t, &civo.StepPowerOff{}, multistep.ActionContinue)
+
+	if got := e.client.History(instance.ID); got[len(got)-1] != civofake.StatusShutoff {
+		t.Fatalf("instance went through %v", got)
+	}
+}
+
+func TestStepPowerOffAlreadyOff(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.addInstance(civogo.Instance{Status: civofake.StatusShutoff})
+
+	e.run(t, &civo.StepPowerOff{}, multistep.ActionContinue)
+
+	if called(e.client, "StopInstance") {
+		t.Fatal("stopped an instance that was already off")
+	}
+}
@@ -8,8 +8,9 @@ import (
 	"time"
 
 	"github.com/civo/civogo"
-	"github.com/hashicorp/packer/helper/multistep"
-	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 type stepSnapshot struct {
@@ -17,11 +18,10 @@ type stepSnapshot struct {
 }
 
 func (s *stepSnapshot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	client := state.Get("client").(*civogo.Client)
-	ui := state.Get("ui").(packer.Ui)
+	client := state.Get("client").(CivoAPI)
+	ui := state.Get("ui").(packersdk.Ui)
 	c := state.Get("config").(*Config)
 	instanceID := state.Get("instance_id").(string)
-	var snapshotRegions []string
 	var snapShotConfig = &civogo.SnapshotConfig{
 		InstanceID: instanceID,
 		Safe:       true,
@@ -41,7 +41,7 @@ func (s *stepSnapshot) Run(ctx context.Context, state multistep.StateBag) multis
 	// because action can take a long time and may depend on the size of the final snapshot,
 	// the timeout is parameterized
 	ui.Say("Waiting for snapshot to complete...")
-	if err := waitForActionState("complete", action.ID, client, s.snapshotTimeout); err != nil {
+	if err := waitForSnapshotState(ctx, ui, "complete", action.ID, client, s.snapshotTimeout); err != nil {
 		// If we get an error the first time, actually report it
 		err := fmt.Errorf("Error waiting for snapshot: %s", err)
 		state.Put("error", err)
@@ -49,19 +49,9 @@ func (s *stepSnapshot) Run(ctx context.Context, state multistep.StateBag) multis
 		return multistep.ActionHalt
 	}
 
-	// Wait for the instance to become unlocked first. For snapshots
-	// this can end up taking quite a long time, so we hardcode this to
-	// 20 minutes.
-	// if err := waitForInstanceUnlocked(client, instanceID, 20*time.Minute); err != nil {
-	// 	// If we get an error the first time, actually report it
-	// 	err := fmt.Errorf("Error shutting down instance: %s", err)
-	// 	state.Put("error", err)
-	// 	ui.Error(err.Error())
-	// 	return multistep.ActionHalt
-	// }
-
-	log.Printf("Looking up snapshot ID for snapshot: %s", c.SnapshotName)
-	images, err := client.FindSnapshot(c.SnapshotName)
+	// Snapshot names are not unique, so only ever look the snapshot up by
+	// the ID it was created with
+	images, err := getSnapshot(client, action.ID)
 	if err != nil {
 		err := fmt.Errorf("Error looking up snapshot ID: %s", err)
 		state.Put("error", err)
@@ -78,12 +68,27 @@ func (s *stepSnapshot) Run(ctx context.Context, state multistep.StateBag) multis
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
-	snapshotRegions = append(snapshotRegions, c.Region)
-
 	log.Printf("Snapshot image ID: %s", imageID)
 	state.Put("snapshot_id", imageID)
+	state.Put("snapshot", images)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("SnapshotID", imageID)
 	state.Put("snapshot_name", c.SnapshotName)
-	state.Put("regions", snapshotRegions)
+	state.Put("regions", c.SnapshotRegions)
+
+	if replaced, ok := state.GetOk("replaced_snapshot_ids"); ok {
+		for _, id := range replaced.([]string) {
+			if id == imageID {
+				// The API overwrote this one in place
+				continue
+			}
+			ui.Say(fmt.Sprintf("Deleting the snapshot replaced by %s (ID: %s)...", c.SnapshotName, id))
+			if _, err := client.DeleteSnapshot(id); err != nil && !isNotFound(err) {
+				ui.Error(fmt.Sprintf(
+					"Error deleting the replaced snapshot %s. Please delete it manually: %s", id, err))
+			}
+		}
+	}
 
 	return multistep.ActionContinue
 }
@@ -91,3 +96,19 @@ func (s *stepSnapshot) Run(ctx context.Context, state multistep.StateBag) multis
 func (s *stepSnapshot) Cleanup(state multistep.StateBag) {
 	// no cleanup
 }
+
+// getSnapshot returns the snapshot with exactly the given ID. Unlike
+// FindSnapshot it can not match another snapshot whose name or ID
+// contains id.
+func getSnapshot(client CivoAPI, id string) (*civogo.Snapshot, error) {
+	snapshots, err := client.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for i := range snapshots {
+		if snapshots[i].ID == id {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unable to find snapshot %s", civogo.ZeroMatchesError, id)
+}
@@ -0,0 +1,79 @@
+package civo_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civogo"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepSnapshot(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.addInstance(civogo.Instance{Status: civofake.StatusShutoff})
+	// Names containing the new snapshot's must not get in the way
+	e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test-old", Region: "LON1", State: "complete"})
+	e.client.AddSnapshot(civogo.Snapshot{Name: "old-packer-test", Region: "LON1", State: "complete"})
+
+	e.run(t, civo.NewStepSnapshot(time.Hour), multistep.ActionContinue)
+
+	snapshot := e.state.Get("snapshot").(*civogo.Snapshot)
+	if snapshot.Name != "packer-test" || snapshot.State != civofake.SnapshotComplete {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	if got := e.state.Get("snapshot_id"); got != snapshot.ID {
+		t.Fatalf("snapshot_id is %v, want %s", got, snapshot.ID)
+	}
+	if got := e.client.History(snapshot.ID); !reflect.DeepEqual(got, []string{"pending", "complete"}) {
+		t.Fatalf("snapshot went through %v", got)
+	}
+}
+
+func TestStepSnapshotTimeout(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.client.SnapshotPolls = 1000
+	e.addInstance(civogo.Instance{Status: civofake.StatusShutoff})
+
+	e.run(t, civo.NewStepSnapshot(time.Minute), multistep.ActionHalt)
+}
+
+func TestStepSnapshotReplaces(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.addInstance(civogo.Instance{Status: civofake.StatusShutoff})
+	old := e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "LON1", State: "complete"})
+	elsewhere := e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "NYC1", State: "complete"})
+
+	e.run(t, &civo.StepCheckSnapshotName{Force: true}, multistep.ActionContinue)
+	if got := e.state.Get("replaced_snapshot_ids"); !reflect.DeepEqual(got, []string{old.ID}) {
+		t.Fatalf("replacing %v, want only %s", got, old.ID)
+	}
+	e.run(t, civo.NewStepSnapshot(time.Hour), multistep.ActionContinue)
+
+	// Overwritten in place, by ID
+	if got := e.state.Get("snapshot_id"); got != old.ID {
+		t.Fatalf("snapshot_id is %v, want the overwritten %s", got, old.ID)
+	}
+	if snapshots := e.client.Snapshots(); len(snapshots) != 2 {
+		t.Fatalf("snapshots left: %+v", snapshots)
+	}
+	for _, s := range e.client.Snapshots() {
+		if s.ID == elsewhere.ID && s.InstanceID != "" {
+			t.Fatal("the snapshot of another region was overwritten")
+		}
+	}
+}
+
+func TestStepCheckSnapshotName(t *testing.T) {
+	e := newStepEnv(t, nil)
+	e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test-2", Region: "LON1", State: "complete"})
+	e.run(t, &civo.StepCheckSnapshotName{}, multistep.ActionContinue)
+
+	e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "NYC1", State: "complete"})
+	e.run(t, &civo.StepCheckSnapshotName{}, multistep.ActionContinue)
+
+	e.client.AddSnapshot(civogo.Snapshot{Name: "packer-test", Region: "LON1", State: "complete"})
+	e.run(t, &civo.StepCheckSnapshotName{}, multistep.ActionHalt)
+}
@@ -0,0 +1,39 @@
+package civo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// tagTemplateData is available when interpolating tags at build time.
+type tagTemplateData struct {
+	BuildName     string
+	SourceImage   string
+	SourceImageID string
+	Region        string
+}
+
+// renderTags interpolates tags, which may refer to facts only known
+// once the build is running such as the source image.
+func renderTags(tags []string, ctx interpolate.Context, data tagTemplateData) ([]string, error) {
+	ctx.Data = &data
+
+	rendered := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := interpolate.Render(tag, &ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error interpolating tag: %s", err)
+		}
+		if tag == "" {
+			continue
+		}
+		if strings.ContainsAny(tag, " \t\n") {
+			return nil, fmt.Errorf("Tag %q must not contain whitespace", tag)
+		}
+		rendered = append(rendered, tag)
+	}
+
+	return rendered, nil
+}
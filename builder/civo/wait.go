@@ -1,155 +1,207 @@
 package civo
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"net/url"
 	"time"
 
 	"github.com/civo/civogo"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-// waitForinstanceState simply blocks until the instance is in
-// a state we expect, while eventually timing out.
-func waitForInstanceState(
-	desiredState string, instanceID string, client *civogo.Client, timeout time.Duration) error {
-	done := make(chan struct{})
-	defer close(done)
-
-	result := make(chan error, 1)
-	go func() {
-		attempts := 0
-		for {
-			attempts++
-
-			log.Printf("Checking instance status... (attempt: %d)", attempts)
-			instance, err := client.GetInstance(instanceID)
-			if err != nil {
-				result <- err
-				return
-			}
+// clock is the source of time used by the poller, so that the
+// back-off behaviour can be driven without really sleeping.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
 
-			if instance.Status == desiredState {
-				result <- nil
-				return
-			}
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// defaultClock is the clock of pollers that are not given one.
+var defaultClock clock = realClock{}
+
+// conditionFunc is called on every poll. It reports whether the
+// desired condition has been reached and a short, human readable
+// status used for progress reporting.
+type conditionFunc func() (done bool, status string, err error)
+
+// poller repeatedly evaluates a condition with exponential back-off
+// until it is met, the timeout expires or the context is cancelled.
+type poller struct {
+	// What we are waiting for, e.g. "instance to become ACTIVE".
+	Description string
+	// The maximum total time to wait.
+	Timeout time.Duration
+	// The delay before the second attempt. Defaults to 2s.
+	InitialInterval time.Duration
+	// The upper bound of the delay between two attempts. Defaults to 30s.
+	MaxInterval time.Duration
+	// The factor the delay grows by after every attempt. Defaults to 1.5.
+	Multiplier float64
+	// The fraction of the delay that is randomised. Defaults to 0.2.
+	Jitter float64
+	// Optional UI used to report status changes.
+	Ui packersdk.Ui
+
+	clock clock
+	rand  *rand.Rand
+}
 
-			// Wait 3 seconds in between
-			time.Sleep(3 * time.Second)
+// Wait blocks until the condition is met. Transient API errors are
+// logged and retried, any other error is returned straight away.
+func (p *poller) Wait(ctx context.Context, condition conditionFunc) error {
+	p.setDefaults()
 
-			// Verify we shouldn't exit
-			select {
-			case <-done:
-				// We finished, so just exit the goroutine
-				return
-			default:
-				// Keep going
-			}
+	deadline := p.clock.Now().Add(p.Timeout)
+	interval := p.InitialInterval
+	lastStatus := ""
+
+	log.Printf("Waiting for up to %s for %s", p.Timeout, p.Description)
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("Cancelled while waiting for %s: %s", p.Description, err)
 		}
-	}()
-
-	log.Printf("Waiting for up to %d seconds for instance to become %s", timeout/time.Second, desiredState)
-	select {
-	case err := <-result:
-		return err
-	case <-time.After(timeout):
-		err := fmt.Errorf("Timeout while waiting to for instance to become '%s'", desiredState)
-		return err
-	}
-}
 
-// waitForActionState simply blocks until the instance action is in
-// a state we expect, while eventually timing out.
-func waitForActionState(desiredState string, action string, client *civogo.Client, timeout time.Duration) error {
-	done := make(chan struct{})
-	defer close(done)
-
-	result := make(chan error, 1)
-	go func() {
-		attempts := 0
-		for {
-			attempts++
-
-			log.Printf("Checking action status... (attempt: %d)", attempts)
-			action, err := client.FindSnapshot(action)
-			if err != nil {
-				result <- err
-				return
+		log.Printf("Checking status of %s... (attempt: %d)", p.Description, attempt)
+		done, status, err := condition()
+		switch {
+		case err != nil && !isTransientError(err):
+			return err
+		case err != nil:
+			log.Printf("Transient error while waiting for %s, retrying: %s", p.Description, err)
+		case done:
+			return nil
+		case status != lastStatus:
+			if p.Ui != nil && status != "" {
+				p.Ui.Message(fmt.Sprintf("Current status: %s", status))
 			}
+			lastStatus = status
+		}
 
-			if action.State == desiredState {
-				result <- nil
-				return
-			}
+		remaining := deadline.Sub(p.clock.Now())
+		if remaining <= 0 {
+			return fmt.Errorf("Timeout while waiting for %s", p.Description)
+		}
 
-			// Wait 3 seconds in between
-			time.Sleep(3 * time.Second)
+		delay := p.jittered(interval)
+		if delay > remaining {
+			delay = remaining
+		}
 
-			// Verify we shouldn't exit
-			select {
-			case <-done:
-				// We finished, so just exit the goroutine
-				return
-			default:
-				// Keep going
-			}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Cancelled while waiting for %s: %s", p.Description, ctx.Err())
+		case <-p.clock.After(delay):
+		}
+
+		interval = time.Duration(float64(interval) * p.Multiplier)
+		if interval > p.MaxInterval {
+			interval = p.MaxInterval
 		}
-	}()
-
-	log.Printf("Waiting for up to %d seconds for action to become %s", timeout/time.Second, desiredState)
-	select {
-	case err := <-result:
-		return err
-	case <-time.After(timeout):
-		err := fmt.Errorf("Timeout while waiting to for action to become '%s'", desiredState)
-		return err
 	}
 }
 
-// WaitForImageState simply blocks until the image action is in
-// a state we expect, while eventually timing out.
-func WaitForImageState(
-	desiredState string, imageID string, client *civogo.Client, timeout time.Duration) error {
-	done := make(chan struct{})
-	defer close(done)
-
-	result := make(chan error, 1)
-	go func() {
-		attempts := 0
-		for {
-			attempts++
-
-			log.Printf("Checking action status... (attempt: %d)", attempts)
-			action, err := client.FindSnapshot(imageID)
-			if err != nil {
-				result <- err
-				return
-			}
+func (p *poller) setDefaults() {
+	if p.InitialInterval == 0 {
+		p.InitialInterval = 2 * time.Second
+	}
+	if p.MaxInterval == 0 {
+		p.MaxInterval = 30 * time.Second
+	}
+	if p.MaxInterval < p.InitialInterval {
+		p.MaxInterval = p.InitialInterval
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 1.5
+	}
+	if p.Jitter <= 0 || p.Jitter >= 1 {
+		p.Jitter = 0.2
+	}
+	if p.clock == nil {
+		p.clock = defaultClock
+	}
+	if p.rand == nil {
+		p.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+}
 
-			if action.State == desiredState {
-				result <- nil
-				return
-			}
+// now returns the current time on the clock of the poller, for
+// conditions that keep time themselves.
+func (p *poller) now() time.Time {
+	p.setDefaults()
+	return p.clock.Now()
+}
 
-			// Wait 3 seconds in between
-			time.Sleep(3 * time.Second)
+// jittered spreads d uniformly over [d*(1-Jitter), d*(1+Jitter)].
+func (p *poller) jittered(d time.Duration) time.Duration {
+	delta := p.Jitter * float64(d)
+	return time.Duration(float64(d) - delta + p.rand.Float64()*2*delta)
+}
 
-			// Verify we shouldn't exit
-			select {
-			case <-done:
-				// We finished, so just exit the goroutine
-				return
-			default:
-				// Keep going
-			}
+// isTransientError reports whether err is worth retrying: server side
+// failures, rate limiting and dropped connections. These only reach the
+// poller undecoded from the calls apiClient reimplements, see client.go.
+func isTransientError(err error) bool {
+	var httpErr civogo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code >= 500 || httpErr.Code == 429
+	}
+
+	// Every failure to get a response from the API, e.g. a connection
+	// reset or refused, comes wrapped in a *url.Error.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+
+	// What is left of the above once decoded by civogo: network timeouts,
+	// and the HTML error pages served by the load balancers on 5xx.
+	return errors.Is(err, civogo.TimeoutError) || errors.Is(err, civogo.ResponseDecodeFailedError)
+}
+
+// waitForInstanceState blocks until the instance is in the desired
+// state, the timeout expires or ctx is cancelled.
+func waitForInstanceState(
+	ctx context.Context, ui packersdk.Ui, desiredState string, instanceID string, client CivoAPI, timeout time.Duration) error {
+	p := &poller{
+		Description: fmt.Sprintf("instance to become %s", desiredState),
+		Timeout:     timeout,
+		Ui:          ui,
+	}
+
+	return p.Wait(ctx, func() (bool, string, error) {
+		instance, err := client.GetInstance(instanceID)
+		if err != nil {
+			return false, "", err
 		}
-	}()
-
-	log.Printf("Waiting for up to %d seconds for image transfer to become %s", timeout/time.Second, desiredState)
-	select {
-	case err := <-result:
-		return err
-	case <-time.After(timeout):
-		err := fmt.Errorf("Timeout while waiting to for image transfer to become '%s'", desiredState)
-		return err
+		return instance.Status == desiredState, instance.Status, nil
+	})
+}
+
+// waitForSnapshotState blocks until the snapshot is in the desired
+// state, the timeout expires or ctx is cancelled.
+func waitForSnapshotState(
+	ctx context.Context, ui packersdk.Ui, desiredState string, snapshotID string, client CivoAPI, timeout time.Duration) error {
+	p := &poller{
+		Description: fmt.Sprintf("snapshot to become %s", desiredState),
+		Timeout:     timeout,
+		MaxInterval: time.Minute,
+		Ui:          ui,
 	}
+
+	return p.Wait(ctx, func() (bool, string, error) {
+		snapshot, err := getSnapshot(client, snapshotID)
+		if err != nil {
+			return false, "", err
+		}
+		return snapshot.State == desiredState, snapshot.State, nil
+	})
 }
@@ -0,0 +1,269 @@
+package civo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/civo/civogo"
+)
+
+// fakeClock fires every timer straight away, moving the time forward by
+// its duration, and records the delays it was asked for.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// stuckClock never fires.
+type stuckClock struct{ fakeClock }
+
+func (c *stuckClock) After(d time.Duration) <-chan time.Time { return nil }
+
+func newTestPoller(clk clock) *poller {
+	return &poller{
+		Description:     "test",
+		Timeout:         time.Hour,
+		InitialInterval: 2 * time.Second,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      1.5,
+		Jitter:          0.2,
+		clock:           clk,
+		rand:            rand.New(rand.NewSource(1)),
+	}
+}
+
+// untilAttempt returns a condition met on the given attempt.
+func untilAttempt(n int) conditionFunc {
+	attempts := 0
+	return func() (bool, string, error) {
+		attempts++
+		return attempts >= n, "waiting", nil
+	}
+}
+
+func assertWithinJitter(t *testing.T, got, want time.Duration, jitter float64) {
+	t.Helper()
+	low := time.Duration(float64(want) * (1 - jitter))
+	high := time.Duration(float64(want) * (1 + jitter))
+	if got < low || got > high {
+		t.Fatalf("delay %s not within [%s, %s]", got, low, high)
+	}
+}
+
+func TestPollerBackOff(t *testing.T) {
+	clk := &fakeClock{}
+	p := newTestPoller(clk)
+
+	if err := p.Wait(context.Background(), untilAttempt(10)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []time.Duration{
+		2 * time.Second,
+		3 * time.Second,
+		4500 * time.Millisecond,
+		6750 * time.Millisecond,
+		10125 * time.Millisecond,
+		15187500 * time.Microsecond,
+		22781250 * time.Microsecond,
+		30 * time.Second,
+		30 * time.Second,
+	}
+	if len(clk.delays) != len(want) {
+		t.Fatalf("got %d delays, want %d: %v", len(clk.delays), len(want), clk.delays)
+	}
+	for i, d := range clk.delays {
+		assertWithinJitter(t, d, want[i], p.Jitter)
+	}
+}
+
+func TestPollerMaxInterval(t *testing.T) {
+	clk := &fakeClock{}
+	p := newTestPoller(clk)
+	p.MaxInterval = 5 * time.Second
+
+	if err := p.Wait(context.Background(), untilAttempt(20)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, d := range clk.delays {
+		if max := time.Duration(float64(p.MaxInterval) * (1 + p.Jitter)); d > max {
+			t.Fatalf("delay %s exceeds %s", d, max)
+		}
+	}
+	for _, d := range clk.delays[len(clk.delays)-5:] {
+		assertWithinJitter(t, d, p.MaxInterval, p.Jitter)
+	}
+}
+
+func TestPollerDefaults(t *testing.T) {
+	p := &poller{MaxInterval: time.Second}
+	p.setDefaults()
+
+	if p.InitialInterval != 2*time.Second {
+		t.Fatalf("InitialInterval = %s", p.InitialInterval)
+	}
+	if p.MaxInterval != p.InitialInterval {
+		t.Fatalf("MaxInterval = %s, want it raised to InitialInterval", p.MaxInterval)
+	}
+	if p.Multiplier != 1.5 || p.Jitter != 0.2 {
+		t.Fatalf("Multiplier = %v, Jitter = %v", p.Multiplier, p.Jitter)
+	}
+}
+
+func TestPollerJitterBounds(t *testing.T) {
+	p := newTestPoller(&fakeClock{})
+	p.Jitter = 0.5
+
+	d := 10 * time.Second
+	var low, high bool
+	for i := 0; i < 10000; i++ {
+		got := p.jittered(d)
+		assertWithinJitter(t, got, d, p.Jitter)
+		low = low || got < d
+		high = high || got > d
+	}
+	if !low || !high {
+		t.Fatal("jitter is not spread on both sides of the delay")
+	}
+}
+
+func TestPollerTimeout(t *testing.T) {
+	clk := &fakeClock{}
+	p := newTestPoller(clk)
+	p.Timeout = time.Minute
+
+	err := p.Wait(context.Background(), func() (bool, string, error) {
+		return false, "", nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "Timeout while waiting for test") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	var total time.Duration
+	for _, d := range clk.delays {
+		total += d
+	}
+	if total != p.Timeout {
+		t.Fatalf("waited %s in total, want exactly the %s timeout", total, p.Timeout)
+	}
+}
+
+func TestPollerCancelled(t *testing.T) {
+	t.Run("before the first attempt", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		called := false
+		err := newTestPoller(&fakeClock{}).Wait(ctx, func() (bool, string, error) {
+			called = true
+			return true, "", nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "Cancelled") {
+			t.Fatalf("expected a cancellation error, got %v", err)
+		}
+		if called {
+			t.Fatal("condition called after cancellation")
+		}
+	})
+
+	t.Run("while sleeping", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		err := newTestPoller(&stuckClock{}).Wait(ctx, func() (bool, string, error) {
+			cancel()
+			return false, "", nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "Cancelled") {
+			t.Fatalf("expected a cancellation error, got %v", err)
+		}
+	})
+}
+
+func TestPollerRetriesTransientErrors(t *testing.T) {
+	errs := []error{
+		civogo.HTTPError{Code: 503, Status: "503 Service Unavailable", Reason: `{"code":"unavailable"}`},
+		civogo.HTTPError{Code: 429, Status: "429 Too Many Requests"},
+		&url.Error{Op: "Get", URL: "https://api.civo.com/v2/instances/1", Err: syscall.ECONNRESET},
+	}
+	attempts := 0
+	err := newTestPoller(&fakeClock{}).Wait(context.Background(), func() (bool, string, error) {
+		if attempts < len(errs) {
+			attempts++
+			return false, "", errs[attempts-1]
+		}
+		return true, "", nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if attempts != len(errs) {
+		t.Fatalf("retried %d errors, want %d", attempts, len(errs))
+	}
+}
+
+func TestPollerStopsOnTerminalErrors(t *testing.T) {
+	terminal := civogo.HTTPError{Code: 404, Status: "404 Not Found"}
+	attempts := 0
+	err := newTestPoller(&fakeClock{}).Wait(context.Background(), func() (bool, string, error) {
+		attempts++
+		return false, "", terminal
+	})
+	if !errors.Is(err, terminal) {
+		t.Fatalf("expected %v, got %v", terminal, err)
+	}
+	if attempts != 1 {
+		t.Fatalf("terminal error retried %d times", attempts-1)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{civogo.HTTPError{Code: 500}, true},
+		{civogo.HTTPError{Code: 502}, true},
+		{civogo.HTTPError{Code: 429}, true},
+		{fmt.Errorf("wrapped: %w", civogo.HTTPError{Code: 503}), true},
+		{&url.Error{Op: "Get", Err: syscall.ECONNREFUSED}, true},
+		{civogo.TimeoutError, true},
+		{civogo.ResponseDecodeFailedError, true},
+		{civogo.HTTPError{Code: 400}, false},
+		{civogo.HTTPError{Code: 401}, false},
+		{civogo.HTTPError{Code: 404}, false},
+		{civogo.DatabaseInstanceNotFoundError, false},
+		{civogo.MultipleMatchesError, false},
+		{civogo.UnknowError, false},
+		{errors.New("boom"), false},
+	}
+	for _, tc := range cases {
+		if got := isTransientError(tc.err); got != tc.want {
+			t.Errorf("isTransientError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
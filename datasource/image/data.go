@@ -0,0 +1,202 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type DatasourceOutput,Config
+
+// Package image contains a packer.Datasource looking up a Civo template
+// or snapshot to build from.
+package image
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Image types returned by the data source.
+const (
+	TypeTemplate = "template"
+	TypeSnapshot = "snapshot"
+)
+
+// Config of the civo-image data source
+type Config struct {
+	// The client TOKEN to use to access your account. It can also be
+	// specified via environment variable CIVO_TOKEN, if set.
+	APIToken string `mapstructure:"api_token" required:"true"`
+	// The region snapshots are looked up in.
+	Region string `mapstructure:"region" required:"true"`
+	// The exact code or name of the template, or name of the snapshot.
+	Name string `mapstructure:"name" required:"false"`
+	// A regular expression the code or name of the template, or name of
+	// the snapshot, must match. Ignored when `name` is set.
+	NameRegex string `mapstructure:"name_regex" required:"false"`
+	// Only look up images of this type, `template` or `snapshot`. By
+	// default both are searched.
+	Type string `mapstructure:"type" required:"false"`
+	// When several snapshots match, return the one that completed last
+	// instead of failing.
+	MostRecent bool `mapstructure:"most_recent" required:"false"`
+}
+
+// Datasource looks up a Civo image
+type Datasource struct {
+	config Config
+
+	nameRegex *regexp.Regexp
+
+	// Client, when set, is used instead of a client created from
+	// api_token. This allows running the data source against a fake API.
+	Client civo.CivoAPI
+}
+
+// DatasourceOutput is the image found by the data source
+type DatasourceOutput struct {
+	// The ID of the template or snapshot.
+	ID string `mapstructure:"id"`
+	// The template code or snapshot name.
+	Name string `mapstructure:"name"`
+	// Either `template` or `snapshot`.
+	Type string `mapstructure:"type"`
+	// The region the image is available in.
+	Region string `mapstructure:"region"`
+}
+
+// ConfigSpec ...
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+// Configure ...
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	if d.config.APIToken == "" {
+		d.config.APIToken = os.Getenv("CIVO_TOKEN")
+	}
+
+	var errs *packersdk.MultiError
+	if d.config.APIToken == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("api_token for auth must be specified"))
+	}
+	if d.config.Region == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("region is required"))
+	}
+	if d.config.Name == "" && d.config.NameRegex == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("one of name or name_regex is required"))
+	}
+	if d.config.NameRegex != "" {
+		re, err := regexp.Compile(d.config.NameRegex)
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("invalid name_regex: %s", err))
+		}
+		d.nameRegex = re
+	}
+	switch d.config.Type {
+	case "", TypeTemplate, TypeSnapshot:
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+			"type must be %q or %q, got %q", TypeTemplate, TypeSnapshot, d.config.Type))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	packersdk.LogSecretFilter.Set(d.config.APIToken)
+	return nil
+}
+
+// OutputSpec ...
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+// Execute ...
+func (d *Datasource) Execute() (cty.Value, error) {
+	nullValue := cty.NullVal(cty.DynamicPseudoType)
+
+	client := d.Client
+	if client == nil {
+		c, err := civo.NewClient(d.config.APIToken)
+		if err != nil {
+			return nullValue, fmt.Errorf("civo: %s", err)
+		}
+		client = c
+	}
+
+	var matches []DatasourceOutput
+	var completedAt = make(map[string]int64)
+
+	if d.config.Type != TypeSnapshot {
+		templates, err := client.ListTemplates()
+		if err != nil {
+			return nullValue, fmt.Errorf("Error listing templates: %s", err)
+		}
+		for _, t := range templates {
+			if d.matches(t.Code) || d.matches(t.Name) {
+				matches = append(matches, DatasourceOutput{
+					ID:     t.ID,
+					Name:   t.Code,
+					Type:   TypeTemplate,
+					Region: d.config.Region,
+				})
+			}
+		}
+	}
+
+	if d.config.Type != TypeTemplate {
+		snapshots, err := client.ListSnapshots()
+		if err != nil {
+			return nullValue, fmt.Errorf("Error listing snapshots: %s", err)
+		}
+		for _, s := range snapshots {
+			if s.Region == d.config.Region && s.State == "complete" && d.matches(s.Name) {
+				matches = append(matches, DatasourceOutput{
+					ID:     s.ID,
+					Name:   s.Name,
+					Type:   TypeSnapshot,
+					Region: s.Region,
+				})
+				completedAt[s.ID] = s.CompletedAt.UnixNano()
+			}
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return nullValue, fmt.Errorf("No image in %s matches the given filters", d.config.Region)
+	case len(matches) > 1 && !d.config.MostRecent:
+		return nullValue, fmt.Errorf("%d images match the given filters, narrow them down or set most_recent", len(matches))
+	}
+
+	if len(matches) > 1 {
+		for _, m := range matches {
+			if m.Type != TypeSnapshot {
+				return nullValue, fmt.Errorf("Several images match, including templates, which can not be ordered by date")
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			return completedAt[matches[i].ID] > completedAt[matches[j].ID]
+		})
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(matches[0], d.OutputSpec()), nil
+}
+
+func (d *Datasource) matches(name string) bool {
+	if d.config.Name != "" {
+		return name == d.config.Name
+	}
+	return d.nameRegex.MatchString(name)
+}
@@ -0,0 +1,125 @@
+package image_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civo-packer/datasource/image"
+	"github.com/civo/civogo"
+)
+
+func TestExecute(t *testing.T) {
+	now := time.Now()
+	client := civofake.NewClient()
+	client.SnapshotPolls = 1000
+	client.AddTemplate(civogo.Template{Code: "ubuntu-focal", Name: "Ubuntu 20.04"})
+	client.AddTemplate(civogo.Template{Code: "ubuntu-jammy", Name: "Ubuntu 22.04"})
+	client.AddSnapshot(civogo.Snapshot{Name: "web-1", Region: "LON1", State: "complete", CompletedAt: now.Add(-2 * time.Hour)})
+	newest := client.AddSnapshot(civogo.Snapshot{Name: "web-2", Region: "LON1", State: "complete", CompletedAt: now.Add(-time.Hour)})
+	client.AddSnapshot(civogo.Snapshot{Name: "web-3", Region: "LON1", State: "complete", CompletedAt: now.Add(-3 * time.Hour)})
+	client.AddSnapshot(civogo.Snapshot{Name: "web-4", Region: "LON1", State: "pending"})
+	client.AddSnapshot(civogo.Snapshot{Name: "web-5", Region: "NYC1", State: "complete", CompletedAt: now})
+	client.AddSnapshot(civogo.Snapshot{Name: "ubuntu-snap", Region: "LON1", State: "complete", CompletedAt: now})
+
+	cases := []struct {
+		name     string
+		raw      map[string]interface{}
+		wantID   string
+		wantName string
+		wantType string
+		err      string
+	}{
+		{
+			name:     "template by code",
+			raw:      map[string]interface{}{"name": "debian-buster"},
+			wantName: "debian-buster",
+			wantType: image.TypeTemplate,
+		},
+		{
+			name:     "template by name",
+			raw:      map[string]interface{}{"name": "Ubuntu 20.04"},
+			wantName: "ubuntu-focal",
+			wantType: image.TypeTemplate,
+		},
+		{
+			name:     "snapshot by name",
+			raw:      map[string]interface{}{"name": "web-2"},
+			wantID:   newest.ID,
+			wantName: "web-2",
+			wantType: image.TypeSnapshot,
+		},
+		{
+			name: "several snapshots",
+			raw:  map[string]interface{}{"name_regex": "^web-"},
+			err:  "3 images match the given filters",
+		},
+		{
+			name:     "most recent snapshot",
+			raw:      map[string]interface{}{"name_regex": "^web-", "most_recent": true},
+			wantID:   newest.ID,
+			wantName: "web-2",
+			wantType: image.TypeSnapshot,
+		},
+		{
+			name: "templates can not be ordered",
+			raw:  map[string]interface{}{"name_regex": "^ubuntu-", "most_recent": true},
+			err:  "including templates, which can not be ordered by date",
+		},
+		{
+			name:     "only snapshots",
+			raw:      map[string]interface{}{"name_regex": "^ubuntu-", "type": image.TypeSnapshot},
+			wantName: "ubuntu-snap",
+			wantType: image.TypeSnapshot,
+		},
+		{
+			name: "only templates",
+			raw:  map[string]interface{}{"name_regex": "^ubuntu-", "type": image.TypeTemplate},
+			err:  "2 images match the given filters",
+		},
+		{
+			name: "pending snapshot",
+			raw:  map[string]interface{}{"name": "web-4"},
+			err:  "No image in LON1 matches the given filters",
+		},
+		{
+			name: "snapshot in another region",
+			raw:  map[string]interface{}{"name": "web-5"},
+			err:  "No image in LON1 matches the given filters",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["api_token"] = "token"
+			tc.raw["region"] = "LON1"
+			d := &image.Datasource{Client: client}
+			if err := d.Configure(tc.raw); err != nil {
+				t.Fatalf("Configure: %s", err)
+			}
+
+			val, err := d.Execute()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tc.wantID != "" && val.GetAttr("id").AsString() != tc.wantID {
+				t.Errorf("id = %s, want %s", val.GetAttr("id").AsString(), tc.wantID)
+			}
+			if got := val.GetAttr("name").AsString(); got != tc.wantName {
+				t.Errorf("name = %s, want %s", got, tc.wantName)
+			}
+			if got := val.GetAttr("type").AsString(); got != tc.wantType {
+				t.Errorf("type = %s, want %s", got, tc.wantType)
+			}
+			if got := val.GetAttr("region").AsString(); got != "LON1" {
+				t.Errorf("region = %s, want LON1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type DatasourceOutput,Config
+
+// Package network contains a packer.Datasource looking up a Civo
+// private network.
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Config of the civo-network data source
+type Config struct {
+	// The client TOKEN to use to access your account. It can also be
+	// specified via environment variable CIVO_TOKEN, if set.
+	APIToken string `mapstructure:"api_token" required:"true"`
+	// The region the network is in.
+	Region string `mapstructure:"region" required:"true"`
+	// The label of the network. Exactly one of `label`, `id` and
+	// `default` must be set.
+	Label string `mapstructure:"label" required:"false"`
+	// The ID of the network.
+	ID string `mapstructure:"id" required:"false"`
+	// Select the default network of the region.
+	Default bool `mapstructure:"default" required:"false"`
+}
+
+// Datasource looks up a Civo network
+type Datasource struct {
+	config Config
+
+	// Client, when set, is used instead of a client created from
+	// api_token. This allows running the data source against a fake API.
+	Client civo.CivoAPI
+}
+
+// DatasourceOutput is the network found by the data source
+type DatasourceOutput struct {
+	// The ID of the network.
+	ID string `mapstructure:"id"`
+	// The label of the network.
+	Label string `mapstructure:"label"`
+	// The name of the network.
+	Name string `mapstructure:"name"`
+	// The region the network is in.
+	Region string `mapstructure:"region"`
+	// The CIDR of the network.
+	CIDR string `mapstructure:"cidr"`
+	// Whether this is the default network of the region.
+	Default bool `mapstructure:"default"`
+}
+
+// ConfigSpec ...
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+// Configure ...
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	if d.config.APIToken == "" {
+		d.config.APIToken = os.Getenv("CIVO_TOKEN")
+	}
+
+	var errs *packersdk.MultiError
+	if d.config.APIToken == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("api_token for auth must be specified"))
+	}
+	if d.config.Region == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("region is required"))
+	}
+
+	selectors := 0
+	for _, set := range []bool{d.config.Label != "", d.config.ID != "", d.config.Default} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("exactly one of label, id or default must be set"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	packersdk.LogSecretFilter.Set(d.config.APIToken)
+	return nil
+}
+
+// OutputSpec ...
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+// Execute ...
+func (d *Datasource) Execute() (cty.Value, error) {
+	nullValue := cty.NullVal(cty.DynamicPseudoType)
+
+	client := d.Client
+	if client == nil {
+		c, err := civo.NewClient(d.config.APIToken)
+		if err != nil {
+			return nullValue, fmt.Errorf("civo: %s", err)
+		}
+		client = c
+	}
+
+	networks, err := client.ListNetworks()
+	if err != nil {
+		return nullValue, fmt.Errorf("Error listing networks: %s", err)
+	}
+
+	for _, n := range networks {
+		if n.Region != "" && n.Region != d.config.Region {
+			continue
+		}
+		if (d.config.Label != "" && n.Label == d.config.Label) ||
+			(d.config.ID != "" && n.ID == d.config.ID) ||
+			(d.config.Default && n.Default) {
+			output := DatasourceOutput{
+				ID:      n.ID,
+				Label:   n.Label,
+				Name:    n.Name,
+				Region:  n.Region,
+				CIDR:    n.CIDR,
+				Default: n.Default,
+			}
+			return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+		}
+	}
+
+	return nullValue, fmt.Errorf("No matching network found in %s", d.config.Region)
+}
@@ -0,0 +1,72 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package network
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	APIToken *string `mapstructure:"api_token" required:"true" cty:"api_token" hcl:"api_token"`
+	Region   *string `mapstructure:"region" required:"true" cty:"region" hcl:"region"`
+	Label    *string `mapstructure:"label" required:"false" cty:"label" hcl:"label"`
+	ID       *string `mapstructure:"id" required:"false" cty:"id" hcl:"id"`
+	Default  *bool   `mapstructure:"default" required:"false" cty:"default" hcl:"default"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"api_token": &hcldec.AttrSpec{Name: "api_token", Type: cty.String, Required: false},
+		"region":    &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
+		"label":     &hcldec.AttrSpec{Name: "label", Type: cty.String, Required: false},
+		"id":        &hcldec.AttrSpec{Name: "id", Type: cty.String, Required: false},
+		"default":   &hcldec.AttrSpec{Name: "default", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	ID      *string `mapstructure:"id" cty:"id" hcl:"id"`
+	Label   *string `mapstructure:"label" cty:"label" hcl:"label"`
+	Name    *string `mapstructure:"name" cty:"name" hcl:"name"`
+	Region  *string `mapstructure:"region" cty:"region" hcl:"region"`
+	CIDR    *string `mapstructure:"cidr" cty:"cidr" hcl:"cidr"`
+	Default *bool   `mapstructure:"default" cty:"default" hcl:"default"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"id":      &hcldec.AttrSpec{Name: "id", Type: cty.String, Required: false},
+		"label":   &hcldec.AttrSpec{Name: "label", Type: cty.String, Required: false},
+		"name":    &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"region":  &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
+		"cidr":    &hcldec.AttrSpec{Name: "cidr", Type: cty.String, Required: false},
+		"default": &hcldec.AttrSpec{Name: "default", Type: cty.Bool, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package network_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civo-packer/datasource/network"
+	"github.com/civo/civogo"
+)
+
+func TestExecute(t *testing.T) {
+	client := civofake.NewClient()
+	build := client.AddNetwork(civogo.Network{Label: "build", Name: "cust-build", Region: "LON1", CIDR: "10.1.0.0/24"})
+	client.AddNetwork(civogo.Network{Label: "remote", Region: "NYC1"})
+	def, err := client.GetDefaultNetwork()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		raw    map[string]interface{}
+		wantID string
+		err    string
+	}{
+		{
+			name:   "by label",
+			raw:    map[string]interface{}{"label": "build"},
+			wantID: build.ID,
+		},
+		{
+			name:   "by id",
+			raw:    map[string]interface{}{"id": build.ID},
+			wantID: build.ID,
+		},
+		{
+			name:   "default",
+			raw:    map[string]interface{}{"default": true},
+			wantID: def.ID,
+		},
+		{
+			name: "unknown label",
+			raw:  map[string]interface{}{"label": "nope"},
+			err:  "No matching network found in LON1",
+		},
+		{
+			name: "other region",
+			raw:  map[string]interface{}{"label": "remote"},
+			err:  "No matching network found in LON1",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["api_token"] = "token"
+			tc.raw["region"] = "LON1"
+			d := &network.Datasource{Client: client}
+			if err := d.Configure(tc.raw); err != nil {
+				t.Fatalf("Configure: %s", err)
+			}
+
+			val, err := d.Execute()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := val.GetAttr("id").AsString(); got != tc.wantID {
+				t.Errorf("id = %s, want %s", got, tc.wantID)
+			}
+		})
+	}
+}
+
+func TestConfigureSelector(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"none": {},
+		"two":  {"label": "build", "default": true},
+	}
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			raw["api_token"] = "token"
+			raw["region"] = "LON1"
+			d := &network.Datasource{}
+			if err := d.Configure(raw); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
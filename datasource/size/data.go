@@ -0,0 +1,151 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type DatasourceOutput,Config
+
+// Package size contains a packer.Datasource checking a Civo instance
+// size exists in a region.
+package size
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/civo/civo-packer/builder/civo"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Config of the civo-size data source
+type Config struct {
+	// The client TOKEN to use to access your account. It can also be
+	// specified via environment variable CIVO_TOKEN, if set.
+	APIToken string `mapstructure:"api_token" required:"true"`
+	// The name of the size, e.g. `g2.small`.
+	Name string `mapstructure:"name" required:"true"`
+	// When set, check that the size is available in this region rather
+	// than in the default region of the account.
+	Region string `mapstructure:"region" required:"false"`
+}
+
+// Datasource looks up a Civo instance size
+type Datasource struct {
+	config Config
+
+	// Client, when set, is used instead of a client created from
+	// api_token. This allows running the data source against a fake API.
+	Client civo.CivoAPI
+}
+
+// DatasourceOutput is the size found by the data source
+type DatasourceOutput struct {
+	// The ID of the size.
+	ID string `mapstructure:"id"`
+	// The name of the size, e.g. `g2.small`.
+	Name string `mapstructure:"name"`
+	// A human readable description of the size.
+	Description string `mapstructure:"description"`
+	// The number of CPU cores.
+	CPUCores int `mapstructure:"cpu_cores"`
+	// The amount of memory, in megabytes.
+	RAMMegabytes int `mapstructure:"ram_mb"`
+	// The size of the disk, in gigabytes.
+	DiskGigabytes int `mapstructure:"disk_gb"`
+}
+
+// ConfigSpec ...
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+// Configure ...
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	if d.config.APIToken == "" {
+		d.config.APIToken = os.Getenv("CIVO_TOKEN")
+	}
+
+	var errs *packersdk.MultiError
+	if d.config.APIToken == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("api_token for auth must be specified"))
+	}
+	if d.config.Name == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("name is required"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	packersdk.LogSecretFilter.Set(d.config.APIToken)
+	return nil
+}
+
+// OutputSpec ...
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+// Execute ...
+func (d *Datasource) Execute() (cty.Value, error) {
+	nullValue := cty.NullVal(cty.DynamicPseudoType)
+
+	client := d.Client
+	if client == nil {
+		c, err := civo.NewClient(d.config.APIToken)
+		if err != nil {
+			return nullValue, fmt.Errorf("civo: %s", err)
+		}
+		client = c
+	}
+
+	if d.config.Region != "" {
+		regions, err := client.ListRegions()
+		if err != nil {
+			return nullValue, fmt.Errorf("Error listing regions: %s", err)
+		}
+		found := false
+		for _, r := range regions {
+			if r.Code == d.config.Region {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nullValue, fmt.Errorf("Region %s not found", d.config.Region)
+		}
+	}
+
+	sizes, err := client.ListSizes(d.config.Region)
+	if err != nil {
+		return nullValue, fmt.Errorf("Error listing sizes: %s", err)
+	}
+	for _, s := range sizes {
+		if s.Name != d.config.Name {
+			continue
+		}
+		if !s.Selectable {
+			return nullValue, fmt.Errorf("Size %s can not be used for new instances", s.Name)
+		}
+		output := DatasourceOutput{
+			ID:            s.ID,
+			Name:          s.Name,
+			Description:   s.Description,
+			CPUCores:      s.CPUCores,
+			RAMMegabytes:  s.RAMMegabytes,
+			DiskGigabytes: s.DiskGigabytes,
+		}
+		return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+	}
+
+	if d.config.Region != "" {
+		return nullValue, fmt.Errorf("Size %s is not available in region %s", d.config.Name, d.config.Region)
+	}
+	return nullValue, fmt.Errorf("Size %s not found", d.config.Name)
+}
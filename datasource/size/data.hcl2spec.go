@@ -0,0 +1,68 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package size
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	APIToken *string `mapstructure:"api_token" required:"true" cty:"api_token" hcl:"api_token"`
+	Name     *string `mapstructure:"name" required:"true" cty:"name" hcl:"name"`
+	Region   *string `mapstructure:"region" required:"false" cty:"region" hcl:"region"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"api_token": &hcldec.AttrSpec{Name: "api_token", Type: cty.String, Required: false},
+		"name":      &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"region":    &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	ID            *string `mapstructure:"id" cty:"id" hcl:"id"`
+	Name          *string `mapstructure:"name" cty:"name" hcl:"name"`
+	Description   *string `mapstructure:"description" cty:"description" hcl:"description"`
+	CPUCores      *int    `mapstructure:"cpu_cores" cty:"cpu_cores" hcl:"cpu_cores"`
+	RAMMegabytes  *int    `mapstructure:"ram_mb" cty:"ram_mb" hcl:"ram_mb"`
+	DiskGigabytes *int    `mapstructure:"disk_gb" cty:"disk_gb" hcl:"disk_gb"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"id":          &hcldec.AttrSpec{Name: "id", Type: cty.String, Required: false},
+		"name":        &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"description": &hcldec.AttrSpec{Name: "description", Type: cty.String, Required: false},
+		"cpu_cores":   &hcldec.AttrSpec{Name: "cpu_cores", Type: cty.Number, Required: false},
+		"ram_mb":      &hcldec.AttrSpec{Name: "ram_mb", Type: cty.Number, Required: false},
+		"disk_gb":     &hcldec.AttrSpec{Name: "disk_gb", Type: cty.Number, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,110 @@
+package size_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/civo/civo-packer/builder/civo/civofake"
+	"github.com/civo/civo-packer/datasource/size"
+	"github.com/civo/civogo"
+)
+
+func TestExecute(t *testing.T) {
+	client := civofake.NewClient()
+	client.AddRegion(civogo.Region{Code: "NYC1", Name: "New York 1"})
+	small := client.AddSize(civogo.InstanceSize{Name: "g3.small", CPUCores: 1, RAMMegabytes: 2048, DiskGigabytes: 25, Selectable: true})
+	client.AddSize(civogo.InstanceSize{Name: "g2.small", Selectable: false})
+	client.AddSize(civogo.InstanceSize{Name: "g3.xlarge", Selectable: true}, "LON1")
+
+	cases := []struct {
+		name   string
+		raw    map[string]interface{}
+		wantID string
+		err    string
+	}{
+		{
+			name:   "any region",
+			raw:    map[string]interface{}{"name": "g3.small"},
+			wantID: small.ID,
+		},
+		{
+			name:   "in region",
+			raw:    map[string]interface{}{"name": "g3.small", "region": "NYC1"},
+			wantID: small.ID,
+		},
+		{
+			name: "not selectable",
+			raw:  map[string]interface{}{"name": "g2.small"},
+			err:  "Size g2.small can not be used for new instances",
+		},
+		{
+			name: "not in region",
+			raw:  map[string]interface{}{"name": "g3.xlarge", "region": "NYC1"},
+			err:  "Size g3.xlarge is not available in region NYC1",
+		},
+		{
+			name: "unknown region",
+			raw:  map[string]interface{}{"name": "g3.small", "region": "FRA1"},
+			err:  "Region FRA1 not found",
+		},
+		{
+			name: "unknown size",
+			raw:  map[string]interface{}{"name": "g9.huge"},
+			err:  "Size g9.huge not found",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["api_token"] = "token"
+			d := &size.Datasource{Client: client}
+			if err := d.Configure(tc.raw); err != nil {
+				t.Fatalf("Configure: %s", err)
+			}
+
+			val, err := d.Execute()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := val.GetAttr("id").AsString(); got != tc.wantID {
+				t.Errorf("id = %s, want %s", got, tc.wantID)
+			}
+		})
+	}
+}
+
+func TestExecuteOutput(t *testing.T) {
+	client := civofake.NewClient()
+	client.AddSize(civogo.InstanceSize{
+		Name:          "g3.medium",
+		Description:   "Medium",
+		CPUCores:      2,
+		RAMMegabytes:  4096,
+		DiskGigabytes: 50,
+		Selectable:    true,
+	})
+
+	d := &size.Datasource{Client: client}
+	if err := d.Configure(map[string]interface{}{"api_token": "token", "name": "g3.medium"}); err != nil {
+		t.Fatalf("Configure: %s", err)
+	}
+	val, err := d.Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := val.GetAttr("description").AsString(); got != "Medium" {
+		t.Errorf("description = %s, want Medium", got)
+	}
+	for attr, want := range map[string]int64{"cpu_cores": 2, "ram_mb": 4096, "disk_gb": 50} {
+		got, _ := val.GetAttr(attr).AsBigFloat().Int64()
+		if got != want {
+			t.Errorf("%s = %d, want %d", attr, got, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+packer {
+  required_plugins {
+    civo = {
+      version = ">= 1.1.0"
+      source  = "github.com/civo/civo"
+    }
+  }
+}
+
+variable "civo_token" {
+  type      = string
+  default   = env("CIVO_TOKEN")
+  sensitive = true
+}
+
+source "civo" "debian" {
+  api_token    = var.civo_token
+  template     = "debian-buster"
+  region       = "lon1"
+  size         = "g2.small"
+  ssh_username = "root"
+}
+
+build {
+  sources = ["source.civo.debian"]
+
+  provisioner "shell" {
+    inline = [
+      "apt-get update",
+      "apt-get upgrade -y",
+      "apt-get install nginx-full -y",
+    ]
+  }
+}
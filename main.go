@@ -1,17 +1,31 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/civo/civo-packer/builder/civo"
-	"github.com/hashicorp/packer/packer/plugin"
+	"github.com/civo/civo-packer/datasource/image"
+	"github.com/civo/civo-packer/datasource/network"
+	"github.com/civo/civo-packer/datasource/size"
+	"github.com/civo/civo-packer/version"
+	"github.com/hashicorp/packer-plugin-sdk/plugin"
 )
 
 func main() {
-	server, err := plugin.Server()
-	if err != nil {
-		panic(err)
+	if len(os.Args) > 1 && os.Args[1] == "reap-orphans" {
+		os.Exit(reapOrphans(os.Args[2:]))
 	}
-	if err := server.RegisterBuilder(new(civo.Builder)); err != nil {
-		panic(err)
+
+	pps := plugin.NewSet()
+	pps.RegisterBuilder(plugin.DEFAULT_NAME, new(civo.Builder))
+	pps.RegisterDatasource("image", new(image.Datasource))
+	pps.RegisterDatasource("size", new(size.Datasource))
+	pps.RegisterDatasource("network", new(network.Datasource))
+	pps.SetVersion(version.PluginVersion)
+	err := pps.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
 	}
-	server.Serve()
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/civo/civo-packer/builder/civo"
+)
+
+const reapUsage = `Usage: packer-plugin-civo reap-orphans [options]
+
+  Deletes the instances, reserved IPs, SSH keys and firewalls left behind by
+  Civo builds that were killed before they could clean up. Instances are
+  recognised by the packer-civo tag, everything else by its packer-<uuid>
+  name. Reserved IPs are looked for in every region.
+
+Options:
+`
+
+// reapOrphans runs the reap-orphans command and returns its exit code.
+func reapOrphans(args []string) int {
+	flags := flag.NewFlagSet("reap-orphans", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), reapUsage)
+		flags.PrintDefaults()
+	}
+	token := flags.String("token", "", "Civo API token, defaults to CIVO_TOKEN")
+	olderThan := flags.Duration("older-than", 24*time.Hour, "only delete resources created longer ago than this")
+	dryRun := flags.Bool("dry-run", false, "only report what would be deleted")
+	timeout := flags.Duration("timeout", 5*time.Minute, "how long to keep trying to delete a firewall still in use by an instance being deleted")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+
+	if *token == "" {
+		*token = os.Getenv("CIVO_TOKEN")
+	}
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "A Civo API token is required, set -token or CIVO_TOKEN")
+		return 2
+	}
+
+	client, err := civo.NewClient(*token)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	reaper := &civo.Reaper{
+		Client:    client,
+		OlderThan: *olderThan,
+		DryRun:    *dryRun,
+		Timeout:   *timeout,
+		Out:       os.Stdout,
+	}
+	if err := reaper.Reap(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}